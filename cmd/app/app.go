@@ -6,7 +6,9 @@ package main
 import (
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/devatherock/simple-slack/pkg/slack"
 	"github.com/devatherock/vela-template-tester/pkg/util"
 	log "github.com/sirupsen/logrus"
 )
@@ -14,12 +16,21 @@ import (
 // Initializes log level
 func init() {
 	util.InitLogLevel()
-	log.SetFormatter(&log.TextFormatter{
+	log.SetFormatter(&slack.RedactingFormatter{Formatter: &log.TextFormatter{
 		FullTimestamp: true,
-	})
+	}})
 }
 
 func main() {
+	slack.SetEnvFilter(slack.ParseEnvFilter(os.Getenv("ENV_ALLOWLIST"), os.Getenv("ENV_DENYLIST")))
+	slack.AddSecretsFromEnv()
+	if patterns := os.Getenv("REDACT_PATTERNS"); patterns != "" {
+		err := slack.AddSecretPatterns(strings.Split(patterns, ",")...)
+		if err != nil {
+			log.Fatal("Invalid redact pattern: ", err)
+		}
+	}
+
 	http.HandleFunc("/api/notification", sendNotification)
 	http.HandleFunc("/api/health", checkHealth)
 
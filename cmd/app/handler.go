@@ -4,22 +4,70 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"os"
 
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/pkg/slack"
 	log "github.com/sirupsen/logrus"
 )
 
+// Settings that read files, which the API doesn't accept
+var pluginOnlySettings = []string{"body_file"}
+
 type NotificationRequest struct {
-	Text    string `json:",omitempty"`
-	Channel string `json:",omitempty"`
-	Color   string `json:",omitempty"`
-	Title   string `json:",omitempty"`
-	Webhook string `json:",omitempty"`
-	Token   string `json:",omitempty"`
-	BuildId string `json:"build_id,omitempty"`
+	Text           string            `json:",omitempty"`
+	Channel        string            `json:",omitempty"`
+	Color          string            `json:",omitempty"`
+	Title          string            `json:",omitempty"`
+	Webhook        string            `json:",omitempty"`
+	Token          string            `json:",omitempty"` // CircleCI token
+	BuildId        string            `json:"build_id,omitempty"`
+	Blocks         []slack.Block     `json:",omitempty"`
+	ColorBar       bool              `json:"color_bar,omitempty"`
+	SlackToken     string            `json:"slack_token,omitempty"`
+	Update         bool              `json:",omitempty"` // Posts a message when the build starts and updates it on completion
+	ThreadKey      string            `json:"thread_key,omitempty"`
+	ReplyBroadcast bool              `json:"reply_broadcast,omitempty"`
+	Provider       string            `json:",omitempty"`               // Backend to send the notification to. Defaults to slack
+	ProviderToken  string            `json:"provider_token,omitempty"` // Token of a provider other than Slack, like a PagerDuty routing key. Defaults to slack_token
+	Settings       map[string]string `json:",omitempty"`               // Backend specific settings
+	Fields         []notifier.Field  `json:",omitempty"`
+	Links          []notifier.Link   `json:",omitempty"`
+	Status         string            `json:",omitempty"`               // Build status, for notifications without a build id. Read from the CI environment by default
+	Targets        []notifier.Target `json:",omitempty"`               // Destinations to send the notification to concurrently, in place of the webhook or token
+	FailurePolicy  string            `json:"failure_policy,omitempty"` // When sending to targets fails, any, all or never. Defaults to any
+
+	// Rocket.Chat specific options
+	Alias            string `json:",omitempty"`
+	Avatar           string `json:",omitempty"`
+	Emoji            string `json:",omitempty"`
+	TitleLink        string `json:"title_link,omitempty"`
+	Collapsed        bool   `json:",omitempty"`
+	ImageUrl         string `json:"image_url,omitempty"`
+	RocketChatUrl    string `json:"rocketchat_url,omitempty"`
+	RocketChatUserId string `json:"rocketchat_user_id,omitempty"`
+	RocketChatToken  string `json:"rocketchat_token,omitempty"`
+}
+
+// Body of an error response. Includes the details reported by the backend
+// when the delivery failed
+type ErrorResponse struct {
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Retryable  bool   `json:"retryable"`
+
+	Results []notifier.TargetResult `json:"results,omitempty"` // Outcome of each target, when sending to targets
+}
+
+// Body of the response to a notification sent to targets
+type TargetsResponse struct {
+	Results []notifier.TargetResult `json:"results"`
 }
 
 // Handles /api/notification endpoint. Waits for the supplied build
@@ -29,7 +77,7 @@ func sendNotification(writer http.ResponseWriter, request *http.Request) {
 	requestBody, err := io.ReadAll(request.Body)
 	if err != nil {
 		log.Error("Error reading request: ", err)
-		writer.WriteHeader(400)
+		writeError(request.Context(), writer, 400, err)
 		return
 	}
 
@@ -38,24 +86,141 @@ func sendNotification(writer http.ResponseWriter, request *http.Request) {
 	err = json.Unmarshal(requestBody, &notificationRequest)
 	if err != nil {
 		log.Error("Error parsing request: ", err)
-		writer.WriteHeader(400)
+		writeError(request.Context(), writer, 400, err)
+		return
+	}
+
+	err = validateRequest(notificationRequest)
+	if err != nil {
+		log.Error("Invalid request: ", err)
+		writeError(request.Context(), writer, 400, err)
 		return
 	}
 
-	// Use webhook from environment variable if available
-	if notificationRequest.Webhook == "" {
+	// Use webhook and token from environment variables if available. Targets
+	// fall back to them individually
+	if len(notificationRequest.Targets) == 0 && isSlackProvider(notificationRequest.Provider) && notificationRequest.Webhook == "" && notificationRequest.SlackToken == "" && notificationRequest.RocketChatToken == "" {
 		notificationRequest.Webhook = os.Getenv("SLACK_WEBHOOK")
+		notificationRequest.SlackToken = os.Getenv("SLACK_TOKEN")
 	}
 
-	successStatus, err := notify(notificationRequest)
+	ctx := withSecrets(request.Context(), notificationRequest)
+
+	if len(notificationRequest.Targets) > 0 {
+		sendToTargets(ctx, writer, notificationRequest)
+		return
+	}
+
+	successStatus, slackResponse, err := notify(ctx, notificationRequest)
 	if err != nil {
-		log.Error("Error sending notification: ", err)
-		writer.WriteHeader(400)
+		log.WithContext(ctx).Error("Error sending notification: ", err)
+		writeError(ctx, writer, 400, err)
+	} else if slackResponse.Timestamp != "" {
+		// Return the identifiers of the message posted through the Web API
+		responseBody, _ := json.Marshal(slackResponse)
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(successStatus)
+		writer.Write(responseBody)
+	} else {
+		writer.WriteHeader(successStatus)
+	}
+}
+
+// Sends the notification to the targets of the request and reports the
+// outcome of each target
+func sendToTargets(ctx context.Context, writer http.ResponseWriter, notificationRequest NotificationRequest) {
+	successStatus, results, err := notifyTargets(ctx, notificationRequest)
+	if err != nil {
+		log.WithContext(ctx).Error("Error sending notification: ", err)
+		writeError(ctx, writer, 400, err)
+	} else if results != nil {
+		responseBody, _ := json.Marshal(TargetsResponse{Results: redactResults(ctx, results)})
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(successStatus)
+		writer.Write(responseBody)
 	} else {
 		writer.WriteHeader(successStatus)
 	}
 }
 
+// Rejects the settings that would read files of the server and targets
+// that would read its environment variables, as they are only meant for the
+// plugin
+func validateRequest(notificationRequest NotificationRequest) error {
+	settings := []map[string]string{notificationRequest.Settings}
+	for _, target := range notificationRequest.Targets {
+		if target.WebhookEnv != "" || target.TokenEnv != "" {
+			return errors.New("Target webhook_env and token_env are not supported by the API")
+		}
+		settings = append(settings, target.Settings)
+	}
+
+	for _, targetSettings := range settings {
+		for _, name := range pluginOnlySettings {
+			if targetSettings[name] != "" {
+				return errors.New("Setting " + name + " is not supported by the API")
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns a context that redacts the webhooks and tokens of the request
+// from messages and logs. They are scoped to the request, so that they
+// neither pile up nor get redacted from the messages of other requests
+func withSecrets(ctx context.Context, notificationRequest NotificationRequest) context.Context {
+	secrets := []string{
+		notificationRequest.Webhook,
+		notificationRequest.Token,
+		notificationRequest.SlackToken,
+		notificationRequest.ProviderToken,
+		notificationRequest.RocketChatToken,
+		notificationRequest.Settings["smtp_password"],
+	}
+
+	for _, target := range notificationRequest.Targets {
+		secrets = append(secrets, target.Secrets()...)
+	}
+
+	return slack.WithSecrets(ctx, secrets...)
+}
+
+// Writes the error as a JSON response body. The secrets of the context, and
+// those of the server, are redacted from it, as the caller might not know
+// them, like when the server's webhook is used
+func writeError(ctx context.Context, writer http.ResponseWriter, statusCode int, err error) {
+	errorResponse := ErrorResponse{Message: slack.RedactContext(ctx, err.Error())}
+
+	var deliveryError *notifier.DeliveryError
+	if errors.As(err, &deliveryError) {
+		errorResponse.StatusCode = deliveryError.StatusCode
+		errorResponse.Reason = slack.RedactContext(ctx, deliveryError.Reason)
+		errorResponse.Retryable = deliveryError.Retryable
+	}
+
+	var targetsError *notifier.TargetsError
+	if errors.As(err, &targetsError) {
+		errorResponse.Results = redactResults(ctx, targetsError.Results)
+	}
+
+	responseBody, _ := json.Marshal(errorResponse)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(statusCode)
+	writer.Write(responseBody)
+}
+
+// Redacts the secrets of the context from the errors of the targets
+func redactResults(ctx context.Context, results []notifier.TargetResult) []notifier.TargetResult {
+	redacted := make([]notifier.TargetResult, len(results))
+	for index, result := range results {
+		result.Error = slack.RedactContext(ctx, result.Error)
+		redacted[index] = result
+	}
+
+	return redacted
+}
+
 // Handles /api/health endpoint. Indicates the health of the application
 func checkHealth(writer http.ResponseWriter, request *http.Request) {
 	writer.Write([]byte("UP"))
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,12 +16,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/devatherock/simple-slack/pkg/notifier"
 	"github.com/devatherock/simple-slack/pkg/slack"
 	log "github.com/sirupsen/logrus"
 )
 
 var exitStatuses = []string{"success", "failed", "failing"}
 var httpClient = &http.Client{}
+var slackClient = slack.NewClient(slack.WithTimeout(time.Duration(getIntEnvVariable("SLACK_TIMEOUT_SECS", 30)) * time.Second))
+var providerHttpClient = &http.Client{Timeout: time.Duration(getIntEnvVariable("SLACK_TIMEOUT_SECS", 30)) * time.Second}
+var threadStore = slack.NewMemoryThreadStore()
 
 type CircleCiWorkFlow struct {
 	Name           string `json:",omitempty"`
@@ -29,33 +34,92 @@ type CircleCiWorkFlow struct {
 	PipelineNumber int    `json:"pipeline_number,omitempty"`
 }
 
-func notify(notificationRequest NotificationRequest) (statusCode int, err error) {
+func notify(ctx context.Context, notificationRequest NotificationRequest) (statusCode int, slackResponse slack.SlackResponse, err error) {
 	statusCode = 200
+	slackRequest := buildSlackRequest(notificationRequest)
 
-	slackRequest := slack.SlackRequest{}
-	slackRequest.Text = notificationRequest.Text
-	slackRequest.Color = notificationRequest.Color
-	slackRequest.Title = notificationRequest.Title
-	slackRequest.Channel = notificationRequest.Channel
-	slackRequest.Webhook = notificationRequest.Webhook
+	// Other backends validate their own config
+	if isSlackProvider(notificationRequest.Provider) {
+		if slackRequest.Webhook == "" && slackRequest.Token == "" && slackRequest.RocketChatToken == "" {
+			statusCode = 400
+			err = errors.New("webhook or token not specified")
+			return
+		}
 
-	if slackRequest.Webhook == "" {
-		statusCode = 400
-		err = errors.New("webhook not specified")
-		return
+		if notificationRequest.Update && slackRequest.Token == "" {
+			statusCode = 400
+			err = errors.New("slack token is required to update a message")
+			return
+		}
 	}
 
 	if notificationRequest.BuildId == "" {
 		defaultTextIfMissing(&slackRequest)
-		err = slack.Notify(slackRequest)
+		slackResponse, err = deliver(ctx, notificationRequest, slackRequest, "")
 	} else {
-		statusCode, err = notifyOnBuildCompletion(notificationRequest.BuildId, notificationRequest.Token, slackRequest)
+		statusCode, slackResponse, err = notifyOnBuildCompletion(ctx, notificationRequest, slackRequest)
 	}
 
 	return
 }
 
-func notifyOnBuildCompletion(buildId string, token string, slackRequest slack.SlackRequest) (int, error) {
+// Sends the notification to the targets of the request concurrently. When
+// a build id is specified, the targets are notified on completion of the
+// build
+func notifyTargets(ctx context.Context, notificationRequest NotificationRequest) (int, []notifier.TargetResult, error) {
+	err := notifier.ValidateFailurePolicy(notificationRequest.FailurePolicy)
+	if err != nil {
+		return 400, nil, err
+	}
+
+	slackRequest := buildSlackRequest(notificationRequest)
+	token := notificationRequest.Token
+	if token == "" {
+		token = os.Getenv("CIRCLECI_TOKEN")
+	}
+
+	if notificationRequest.BuildId != "" {
+		if token != "" {
+			go monitor(context.WithoutCancel(ctx), token, notificationRequest, slackRequest)
+			return 204, nil, nil
+		}
+
+		log.Warn("No token found, but build id specified. Build id: ", notificationRequest.BuildId)
+	}
+
+	defaultTextIfMissing(&slackRequest)
+	results, err := deliverToTargets(ctx, notificationRequest, slackRequest, "")
+	if err != nil {
+		return 400, results, err
+	}
+
+	return 200, results, nil
+}
+
+// Forms the Slack request from the request
+func buildSlackRequest(notificationRequest NotificationRequest) slack.SlackRequest {
+	slackRequest := notifier.ToSlackRequest(buildConfig(notificationRequest), buildMessage(notificationRequest))
+	slackRequest.ReplyBroadcast = notificationRequest.ReplyBroadcast
+	slackRequest.ColorBar = slackRequest.ColorBar || notificationRequest.ColorBar
+	if len(notificationRequest.Blocks) > 0 {
+		slackRequest.Blocks = notificationRequest.Blocks
+	}
+	slackRequest.Alias = notificationRequest.Alias
+	slackRequest.Avatar = notificationRequest.Avatar
+	slackRequest.Emoji = notificationRequest.Emoji
+	slackRequest.TitleLink = notificationRequest.TitleLink
+	slackRequest.Collapsed = notificationRequest.Collapsed
+	slackRequest.ImageUrl = notificationRequest.ImageUrl
+	slackRequest.RocketChatUrl = notificationRequest.RocketChatUrl
+	slackRequest.RocketChatUserId = notificationRequest.RocketChatUserId
+	slackRequest.RocketChatToken = notificationRequest.RocketChatToken
+
+	return slackRequest
+}
+
+func notifyOnBuildCompletion(ctx context.Context, notificationRequest NotificationRequest, slackRequest slack.SlackRequest) (int, slack.SlackResponse, error) {
+	buildId := notificationRequest.BuildId
+	token := notificationRequest.Token
 	if token == "" {
 		token = os.Getenv("CIRCLECI_TOKEN")
 	}
@@ -63,23 +127,29 @@ func notifyOnBuildCompletion(buildId string, token string, slackRequest slack.Sl
 	if token == "" {
 		log.Warn("No token found, but build id specified. Build id: ", buildId)
 		defaultTextIfMissing(&slackRequest)
-		err := slack.Notify(slackRequest)
+		slackResponse, err := deliver(ctx, notificationRequest, slackRequest, "")
 
 		if err != nil {
-			return 400, err
+			return 400, slackResponse, err
 		} else {
-			return 200, nil
+			return 200, slackResponse, nil
 		}
 	} else {
-		go monitor(buildId, token, slackRequest)
+		go monitor(context.WithoutCancel(ctx), token, notificationRequest, slackRequest)
 	}
 
-	return 204, nil
+	return 204, slack.SlackResponse{}, nil
 }
 
-func monitor(buildId string, token string, slackRequest slack.SlackRequest) {
+// Polls the build until it completes and then posts the notification. In
+// update mode, a message is posted while the build is running and is updated
+// with the final status on completion
+func monitor(ctx context.Context, token string, notificationRequest NotificationRequest, slackRequest slack.SlackRequest) {
+	buildId := notificationRequest.BuildId
 	log.Info("Monitoring build ", buildId)
 	buildStatus := "running"
+	runningMessage := slack.SlackResponse{}
+	runningPosted := false
 
 	for buildStatus == "running" {
 		circleCiRequest, _ := http.NewRequest("GET", getCircleCiUrl()+"/api/v2/workflow/"+buildId, nil)
@@ -88,7 +158,7 @@ func monitor(buildId string, token string, slackRequest slack.SlackRequest) {
 		// Make request
 		circleCiResponse, err := httpClient.Do(circleCiRequest)
 		if err != nil {
-			log.Error("error: ", err)
+			log.WithContext(ctx).Error("error: ", err)
 			return
 		}
 
@@ -101,15 +171,7 @@ func monitor(buildId string, token string, slackRequest slack.SlackRequest) {
 		buildStatus = circleCiWorkFlow.Status
 		if slices.Contains(exitStatuses, buildStatus) {
 			if slackRequest.Text == "" {
-				slackRequest.Text = fmt.Sprintf(
-					"%s: <https://app.circleci.com/pipelines/%s/%d|%s-%d>(%s)",
-					strings.ToUpper(buildStatus[:1])+buildStatus[1:],
-					circleCiWorkFlow.Project,
-					circleCiWorkFlow.PipelineNumber,
-					circleCiWorkFlow.Project,
-					circleCiWorkFlow.PipelineNumber,
-					circleCiWorkFlow.Name,
-				)
+				slackRequest.Text = buildStatusText(buildStatus, circleCiWorkFlow)
 			}
 
 			if buildStatus == "success" {
@@ -118,9 +180,39 @@ func monitor(buildId string, token string, slackRequest slack.SlackRequest) {
 				slackRequest.Color = "#a1040c"
 			}
 
-			slack.Notify(slackRequest)
+			// Ties the events of a workflow together, for backends like
+			// PagerDuty, as the API can't read the repository and branch
+			// from its environment
+			notificationRequest.Settings = withDefaultSetting(
+				notificationRequest.Settings,
+				"dedup_key",
+				circleCiWorkFlow.Project+"/"+circleCiWorkFlow.Name,
+			)
+
+			// Replace the running message with the final status
+			if len(notificationRequest.Targets) > 0 {
+				deliverToTargets(ctx, notificationRequest, slackRequest, notifier.NormalizeStatus(buildStatus))
+			} else if runningMessage.Timestamp != "" {
+				slackRequest.Channel = runningMessage.Channel
+				slackRequest.Timestamp = runningMessage.Timestamp
+				_, err = slackClient.Send(ctx, slackRequest)
+				if err != nil {
+					log.WithContext(ctx).Error("Error updating notification: ", err)
+				}
+			} else {
+				_, err = deliver(ctx, notificationRequest, slackRequest, notifier.NormalizeStatus(buildStatus))
+				if err != nil {
+					log.WithContext(ctx).Error("Error sending notification: ", err)
+				}
+			}
 			break
 		} else {
+			// Posted only once, as a failed post would fail again on every poll
+			if notificationRequest.Update && isSlackProvider(notificationRequest.Provider) && len(notificationRequest.Targets) == 0 && !runningPosted && buildStatus == "running" {
+				runningMessage = postRunningMessage(ctx, slackRequest, circleCiWorkFlow, notificationRequest.ThreadKey)
+				runningPosted = true
+			}
+
 			// Wait if the build hasn't completed yet
 			log.Debug("Waiting for build ", buildId)
 			time.Sleep(time.Duration(getSleepInterval()) * time.Second)
@@ -130,6 +222,177 @@ func monitor(buildId string, token string, slackRequest slack.SlackRequest) {
 	log.Info("Status of build ", buildId, " on exit is ", buildStatus)
 }
 
+// Posts a message indicating that the build is running, to be updated once
+// the build completes
+func postRunningMessage(ctx context.Context, slackRequest slack.SlackRequest, circleCiWorkFlow CircleCiWorkFlow, threadKey string) slack.SlackResponse {
+	if slackRequest.Text == "" {
+		slackRequest.Text = buildStatusText("running", circleCiWorkFlow)
+	}
+	slackRequest.Color = "#cfd3d7"
+
+	slackResponse, err := post(ctx, slackRequest, threadKey)
+	if err != nil {
+		log.WithContext(ctx).Error("Error posting running message: ", err)
+	}
+
+	return slackResponse
+}
+
+// Posts the message, as a thread reply if a thread key is specified
+func post(ctx context.Context, slackRequest slack.SlackRequest, threadKey string) (slack.SlackResponse, error) {
+	if threadKey == "" {
+		return slackClient.Send(ctx, slackRequest)
+	}
+
+	return slackClient.SendInThread(ctx, slackRequest, threadKey, threadStore)
+}
+
+// Sends the notification through the requested backend. The identifier of a
+// message sent through other backends is reported as its timestamp
+func deliver(ctx context.Context, notificationRequest NotificationRequest, slackRequest slack.SlackRequest, status string) (slack.SlackResponse, error) {
+	if isSlackProvider(notificationRequest.Provider) {
+		return post(ctx, slackRequest, notificationRequest.ThreadKey)
+	}
+
+	message := buildMessage(notificationRequest)
+	message.Text = slackRequest.Text
+	if status != "" {
+		message.Status = status
+	}
+
+	backend, err := notifier.New(notificationRequest.Provider, buildConfig(notificationRequest))
+	if err != nil {
+		return slack.SlackResponse{}, err
+	}
+
+	result, err := backend.Notify(ctx, message)
+	return slack.SlackResponse{Channel: result.Channel, Timestamp: result.Id}, err
+}
+
+// Sends the notification to each of the targets concurrently. Targets
+// without a provider use the provider of the request. The outcome of each
+// target is logged and checked against the failure policy
+func deliverToTargets(ctx context.Context, notificationRequest NotificationRequest, slackRequest slack.SlackRequest, status string) ([]notifier.TargetResult, error) {
+	common := notifier.Config{
+		Webhook:  notificationRequest.Webhook,
+		Token:    providerToken(notificationRequest),
+		Channel:  notificationRequest.Channel,
+		Settings: notificationRequest.Settings,
+	}
+
+	results := notifier.FanOut(ctx, notificationRequest.Targets, func(ctx context.Context, target notifier.Target) (notifier.Result, error) {
+		// The environment of the server is never read for a request
+		target.WebhookEnv, target.TokenEnv = "", ""
+		config := target.Apply(common, notificationRequest.Provider)
+
+		targetRequest := notificationRequest
+		targetRequest.Targets = nil
+		if target.Provider != "" {
+			targetRequest.Provider = target.Provider
+		}
+
+		// Only Slack targets without a webhook or token of their own use
+		// the server's, so that they aren't sent to another destination
+		if isSlackProvider(targetRequest.Provider) && config.Webhook == "" && config.Token == "" && notificationRequest.RocketChatToken == "" {
+			config.Webhook = os.Getenv("SLACK_WEBHOOK")
+			config.Token = os.Getenv("SLACK_TOKEN")
+		}
+
+		targetRequest.Webhook = config.Webhook
+		targetRequest.SlackToken, targetRequest.ProviderToken = config.Token, ""
+		targetRequest.Channel = config.Channel
+		targetRequest.Settings = config.Settings
+
+		// Each target keeps its own threads, as the parent messages differ
+		if targetRequest.ThreadKey != "" {
+			targetRequest.ThreadKey += "/" + target.Name
+		}
+
+		targetSlackRequest := slackRequest
+		targetSlackRequest.Webhook = config.Webhook
+		targetSlackRequest.Token = config.Token
+		targetSlackRequest.Channel = config.Channel
+
+		slackResponse, err := deliver(ctx, targetRequest, targetSlackRequest, status)
+		return notifier.Result{Channel: slackResponse.Channel, Id: slackResponse.Timestamp}, err
+	})
+
+	return results, notifier.CheckResults(ctx, notificationRequest.FailurePolicy, results)
+}
+
+// Forms a backend neutral message from the request
+func buildMessage(notificationRequest NotificationRequest) notifier.Message {
+	return notifier.Message{
+		Text:   notificationRequest.Text,
+		Color:  notificationRequest.Color,
+		Title:  notificationRequest.Title,
+		Status: notificationRequest.Status,
+		Fields: notificationRequest.Fields,
+		Links:  notificationRequest.Links,
+	}
+}
+
+// Forms the config of the notification backend from the request. The thread
+// key is passed on as a setting, for backends that support threads
+func buildConfig(notificationRequest NotificationRequest) notifier.Config {
+	settings := make(map[string]string)
+	if notificationRequest.ThreadKey != "" {
+		settings["thread_key"] = notificationRequest.ThreadKey
+	}
+	for name, value := range notificationRequest.Settings {
+		if !slices.Contains(pluginOnlySettings, name) {
+			settings[name] = value
+		}
+	}
+
+	return notifier.Config{
+		Webhook:    notificationRequest.Webhook,
+		Token:      providerToken(notificationRequest),
+		Channel:    notificationRequest.Channel,
+		Settings:   settings,
+		Retry:      getRetryPolicy(),
+		HttpClient: providerHttpClient,
+	}
+}
+
+// Adds a setting unless specified in the request. Returns a copy, to leave
+// the settings of the request as is
+func withDefaultSetting(settings map[string]string, name string, value string) map[string]string {
+	merged := map[string]string{name: value}
+	for key, settingValue := range settings {
+		merged[key] = settingValue
+	}
+
+	return merged
+}
+
+// Token of the provider of the request. Providers other than Slack use the
+// provider_token, falling back to the slack_token
+func providerToken(notificationRequest NotificationRequest) string {
+	if !isSlackProvider(notificationRequest.Provider) && notificationRequest.ProviderToken != "" {
+		return notificationRequest.ProviderToken
+	}
+
+	return notificationRequest.SlackToken
+}
+
+func isSlackProvider(provider string) bool {
+	return provider == "" || provider == notifier.DefaultProvider
+}
+
+// Builds the default notification text for a CircleCI workflow
+func buildStatusText(buildStatus string, circleCiWorkFlow CircleCiWorkFlow) string {
+	return fmt.Sprintf(
+		"%s: <https://app.circleci.com/pipelines/%s/%d|%s-%d>(%s)",
+		strings.ToUpper(buildStatus[:1])+buildStatus[1:],
+		circleCiWorkFlow.Project,
+		circleCiWorkFlow.PipelineNumber,
+		circleCiWorkFlow.Project,
+		circleCiWorkFlow.PipelineNumber,
+		circleCiWorkFlow.Name,
+	)
+}
+
 func getCircleCiUrl() (circleCiUrl string) {
 	circleCiUrl = os.Getenv("CIRCLECI_API_HOST")
 
@@ -152,6 +415,24 @@ func getSleepInterval() (sleepInterval int) {
 	return
 }
 
+// Reads the retry policy for Slack deliveries from environment variables
+func getRetryPolicy() slack.RetryPolicy {
+	return slack.RetryPolicy{
+		MaxRetries:     getIntEnvVariable("SLACK_MAX_RETRIES", 3),
+		InitialBackoff: time.Duration(getIntEnvVariable("SLACK_RETRY_BACKOFF_SECS", 1)) * time.Second,
+		MaxBackoff:     time.Duration(getIntEnvVariable("SLACK_RETRY_MAX_BACKOFF_SECS", 30)) * time.Second,
+	}
+}
+
+func getIntEnvVariable(variable string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(variable))
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
 func defaultTextIfMissing(slackRequest *slack.SlackRequest) {
 	if slackRequest.Text == "" {
 		slackRequest.Text = "Build completed"
@@ -0,0 +1,18 @@
+//go:build !plugin && !integration
+// +build !plugin,!integration
+
+package main
+
+// Notification backends, registered with the notifier on import
+import (
+	_ "github.com/devatherock/simple-slack/pkg/discord"
+	_ "github.com/devatherock/simple-slack/pkg/email"
+	_ "github.com/devatherock/simple-slack/pkg/generic"
+	_ "github.com/devatherock/simple-slack/pkg/googlechat"
+	_ "github.com/devatherock/simple-slack/pkg/matrix"
+	_ "github.com/devatherock/simple-slack/pkg/mattermost"
+	_ "github.com/devatherock/simple-slack/pkg/pagerduty"
+	_ "github.com/devatherock/simple-slack/pkg/teams"
+	_ "github.com/devatherock/simple-slack/pkg/telegram"
+	_ "github.com/devatherock/simple-slack/pkg/zulip"
+)
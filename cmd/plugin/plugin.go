@@ -1,18 +1,64 @@
 package main
 
 import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/devatherock/simple-slack/pkg/notifier"
 	"github.com/devatherock/simple-slack/pkg/slack"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 )
 
+const defaultThreadFile string = ".slack-threads.json"
+
+// Names of the backend specific flags, passed on to the backends as settings
+var providerSettings = []string{
+	"thread_key",
+	"username",
+	"avatar_url",
+	"content",
+	"zulip_site",
+	"zulip_email",
+	"stream",
+	"topic",
+	"homeserver",
+	"parse_mode",
+	"silent_on_success",
+	"card",
+	"priority",
+	"requested_ack",
+	"default_branch",
+	"dedup_key",
+	"source",
+	"severity",
+	"smtp_host",
+	"smtp_port",
+	"smtp_username",
+	"smtp_password",
+	"smtp_starttls",
+	"email_from",
+	"email_to",
+	"subject",
+	"method",
+	"headers",
+	"body",
+	"body_file",
+	"content_type",
+	"auth_username",
+}
+
 func main() {
-	log.SetFormatter(&log.TextFormatter{
+	log.SetFormatter(&slack.RedactingFormatter{Formatter: &log.TextFormatter{
 		DisableColors: true,
 		FullTimestamp: true,
-	})
+	}})
 	runApp(os.Args)
 }
 
@@ -21,6 +67,14 @@ func runApp(args []string) {
 	app := cli.NewApp()
 	app.Name = "simple slack plugin"
 	app.Action = run
+	app.DisableSliceFlagSeparator = true // Targets are JSON or YAML documents, which contain commas
+	app.Commands = []*cli.Command{
+		{
+			Name:   "functions",
+			Usage:  "Lists the helper functions available to templates, with examples",
+			Action: listFunctions,
+		},
+	}
 	app.Flags = []cli.Flag{
 		createStringCliFlag(
 			"color",
@@ -34,6 +88,18 @@ func runApp(args []string) {
 			"The message content",
 			[]string{"TEXT", "PLUGIN_TEXT", "PARAMETER_TEXT"},
 		),
+		createStringCliFlag(
+			"template_file",
+			[]string{"tpf"},
+			"Path of a file holding the message content, in place of the text",
+			[]string{"TEMPLATE_FILE", "PLUGIN_TEMPLATE_FILE", "PARAMETER_TEMPLATE_FILE"},
+		),
+		createStringCliFlag(
+			"template_dir",
+			[]string{"tpd"},
+			"Directory of *.tmpl files holding named templates. Without a text, the template named after the build status is used",
+			[]string{"TEMPLATE_DIR", "PLUGIN_TEMPLATE_DIR", "PARAMETER_TEMPLATE_DIR"},
+		),
 		createStringCliFlag(
 			"title",
 			[]string{"ti"},
@@ -52,6 +118,382 @@ func runApp(args []string) {
 			"The slack webhook URL",
 			[]string{"WEBHOOK", "PLUGIN_WEBHOOK", "SLACK_WEBHOOK"},
 		),
+		createStringCliFlag(
+			"token",
+			[]string{"tk"},
+			"The slack bot token. When specified, the message is posted using the Web API instead of the webhook",
+			[]string{"TOKEN", "PLUGIN_TOKEN", "SLACK_TOKEN"},
+		),
+		createBoolCliFlag(
+			"update",
+			[]string{"up"},
+			"Flag to update a previously posted message instead of posting a new one",
+			[]string{"UPDATE", "PLUGIN_UPDATE", "PARAMETER_UPDATE"},
+		),
+		createStringCliFlag(
+			"ts",
+			[]string{},
+			"Timestamp of the message to update",
+			[]string{"TS", "PLUGIN_TS", "PARAMETER_TS"},
+		),
+		createStringCliFlag(
+			"ts_file",
+			[]string{"tf"},
+			"File to save the channel ID and timestamp of the posted message to and to read them from when updating",
+			[]string{"TS_FILE", "PLUGIN_TS_FILE", "PARAMETER_TS_FILE"},
+		),
+		createStringCliFlag(
+			"thread_key",
+			[]string{"thk"},
+			"Template of the key that groups messages into a thread. The first message with a key becomes the parent",
+			[]string{"THREAD_KEY", "PLUGIN_THREAD_KEY", "PARAMETER_THREAD_KEY"},
+		),
+		createBoolCliFlag(
+			"reply_broadcast",
+			[]string{"rb"},
+			"Flag to also send thread replies to the channel",
+			[]string{"REPLY_BROADCAST", "PLUGIN_REPLY_BROADCAST", "PARAMETER_REPLY_BROADCAST"},
+		),
+		createStringCliFlag(
+			"thread_file",
+			[]string{"thf"},
+			"File to save the timestamps of the thread parents to",
+			[]string{"THREAD_FILE", "PLUGIN_THREAD_FILE", "PARAMETER_THREAD_FILE"},
+		),
+		createIntCliFlag(
+			"retries",
+			[]string{"r"},
+			"Number of times to retry a failed delivery. Rate limits and server errors are retried",
+			[]string{"RETRIES", "PLUGIN_RETRIES", "PARAMETER_RETRIES"},
+			3,
+		),
+		createDurationCliFlag(
+			"retry_backoff",
+			[]string{"rbo"},
+			"Delay before the first retry. The delay doubles with every retry",
+			[]string{"RETRY_BACKOFF", "PLUGIN_RETRY_BACKOFF", "PARAMETER_RETRY_BACKOFF"},
+			time.Second,
+		),
+		createDurationCliFlag(
+			"retry_max_backoff",
+			[]string{"rmbo"},
+			"Maximum delay between retries, including those requested through Retry-After",
+			[]string{"RETRY_MAX_BACKOFF", "PLUGIN_RETRY_MAX_BACKOFF", "PARAMETER_RETRY_MAX_BACKOFF"},
+			30*time.Second,
+		),
+		createDurationCliFlag(
+			"timeout",
+			[]string{"to"},
+			"Timeout of each HTTP request to Slack",
+			[]string{"TIMEOUT", "PLUGIN_TIMEOUT", "PARAMETER_TIMEOUT"},
+			30*time.Second,
+		),
+		createStringCliFlag(
+			"provider",
+			[]string{"p"},
+			"The chat or notification backend to send the message to. Defaults to slack",
+			[]string{"PROVIDER", "PLUGIN_PROVIDER", "PARAMETER_PROVIDER"},
+		),
+		createStringCliFlag(
+			"fields",
+			[]string{"f"},
+			"JSON or YAML list of fields, with title, value and short, to show alongside the text",
+			[]string{"FIELDS", "PLUGIN_FIELDS", "PARAMETER_FIELDS"},
+		),
+		createStringCliFlag(
+			"links",
+			[]string{"l"},
+			"JSON or YAML list of links, with text and url, to show as buttons",
+			[]string{"LINKS", "PLUGIN_LINKS", "PARAMETER_LINKS"},
+		),
+		createStringCliFlag(
+			"targets",
+			[]string{"tg"},
+			"JSON or YAML list of targets to send the message to concurrently, each with a webhook or token, a channel and an optional provider",
+			[]string{"TARGETS", "PLUGIN_TARGETS", "PARAMETER_TARGETS"},
+		),
+		createStringSliceCliFlag(
+			"target",
+			[]string{"tgt"},
+			"JSON or YAML document of a target to send the message to. Can be repeated",
+			[]string{"TARGET", "PLUGIN_TARGET", "PARAMETER_TARGET"},
+		),
+		createStringCliFlag(
+			"failure_policy",
+			[]string{"fp"},
+			"When sending to targets fails, any, all or never. Defaults to any",
+			[]string{"FAILURE_POLICY", "PLUGIN_FAILURE_POLICY", "PARAMETER_FAILURE_POLICY"},
+		),
+		createStringCliFlag(
+			"env_allowlist",
+			[]string{"eal"},
+			"Comma separated names or globs of the only environment variables templates can read",
+			[]string{"ENV_ALLOWLIST", "PLUGIN_ENV_ALLOWLIST", "PARAMETER_ENV_ALLOWLIST"},
+		),
+		createStringCliFlag(
+			"env_denylist",
+			[]string{"edl"},
+			"Comma separated names or globs of environment variables templates can't read, like *_KEY",
+			[]string{"ENV_DENYLIST", "PLUGIN_ENV_DENYLIST", "PARAMETER_ENV_DENYLIST"},
+		),
+		createStringCliFlag(
+			"redact_patterns",
+			[]string{"rp"},
+			"JSON or YAML list or comma separated regular expressions of secrets to redact from messages and logs",
+			[]string{"REDACT_PATTERNS", "PLUGIN_REDACT_PATTERNS", "PARAMETER_REDACT_PATTERNS"},
+		),
+		createStringCliFlag(
+			"blocks",
+			[]string{"b"},
+			"JSON or YAML document containing Block Kit blocks",
+			[]string{"BLOCKS", "PLUGIN_BLOCKS", "PARAMETER_BLOCKS"},
+		),
+		createBoolCliFlag(
+			"color_bar",
+			[]string{"cb"},
+			"Flag to wrap the blocks in an attachment highlighted with the color",
+			[]string{"COLOR_BAR", "PLUGIN_COLOR_BAR", "PARAMETER_COLOR_BAR"},
+		),
+		createStringCliFlag(
+			"alias",
+			[]string{"al"},
+			"Name the message is posted with, on Rocket.Chat",
+			[]string{"ALIAS", "PLUGIN_ALIAS", "PARAMETER_ALIAS"},
+		),
+		createStringCliFlag(
+			"avatar",
+			[]string{"avt"},
+			"URL of the avatar the message is posted with, on Rocket.Chat",
+			[]string{"AVATAR", "PLUGIN_AVATAR", "PARAMETER_AVATAR"},
+		),
+		createStringCliFlag(
+			"emoji",
+			[]string{"em"},
+			"Emoji used as the avatar, like :rocket:, on Rocket.Chat",
+			[]string{"EMOJI", "PLUGIN_EMOJI", "PARAMETER_EMOJI"},
+		),
+		createStringCliFlag(
+			"title_link",
+			[]string{"tl"},
+			"URL the title links to",
+			[]string{"TITLE_LINK", "PLUGIN_TITLE_LINK", "PARAMETER_TITLE_LINK"},
+		),
+		createBoolCliFlag(
+			"collapsed",
+			[]string{"col"},
+			"Flag to show the attachment collapsed, on Rocket.Chat",
+			[]string{"COLLAPSED", "PLUGIN_COLLAPSED", "PARAMETER_COLLAPSED"},
+		),
+		createStringCliFlag(
+			"image_url",
+			[]string{"img"},
+			"URL of an image shown within the attachment",
+			[]string{"IMAGE_URL", "PLUGIN_IMAGE_URL", "PARAMETER_IMAGE_URL"},
+		),
+		createStringCliFlag(
+			"rocketchat_url",
+			[]string{"rcu"},
+			"URL of the Rocket.Chat server. When specified along with a user ID and token, the message is posted using the REST API",
+			[]string{"ROCKETCHAT_URL", "PLUGIN_ROCKETCHAT_URL", "PARAMETER_ROCKETCHAT_URL"},
+		),
+		createStringCliFlag(
+			"rocketchat_user_id",
+			[]string{"rcui"},
+			"ID of the Rocket.Chat user to post the message as",
+			[]string{"ROCKETCHAT_USER_ID", "PLUGIN_ROCKETCHAT_USER_ID", "PARAMETER_ROCKETCHAT_USER_ID"},
+		),
+		createStringCliFlag(
+			"rocketchat_token",
+			[]string{"rct"},
+			"Personal access token of the Rocket.Chat user",
+			[]string{"ROCKETCHAT_TOKEN", "PLUGIN_ROCKETCHAT_TOKEN"},
+		),
+		createStringCliFlag(
+			"username",
+			[]string{"un"},
+			"Overrides the name the message is posted with, on Discord and Mattermost",
+			[]string{"USERNAME", "PLUGIN_USERNAME", "PARAMETER_USERNAME"},
+		),
+		createStringCliFlag(
+			"avatar_url",
+			[]string{"av"},
+			"Overrides the avatar the message is posted with, on Discord and Mattermost",
+			[]string{"AVATAR_URL", "PLUGIN_AVATAR_URL", "PARAMETER_AVATAR_URL"},
+		),
+		createStringCliFlag(
+			"content",
+			[]string{"co"},
+			"Plain text posted above the embed on Discord, for mentions",
+			[]string{"CONTENT", "PLUGIN_CONTENT", "PARAMETER_CONTENT"},
+		),
+		createStringCliFlag(
+			"zulip_site",
+			[]string{"zs"},
+			"URL of the Zulip organization, like https://example.zulipchat.com",
+			[]string{"ZULIP_SITE", "PLUGIN_ZULIP_SITE", "PARAMETER_ZULIP_SITE"},
+		),
+		createStringCliFlag(
+			"zulip_email",
+			[]string{"ze"},
+			"Email of the Zulip bot. Its API key is read from the token",
+			[]string{"ZULIP_EMAIL", "PLUGIN_ZULIP_EMAIL", "PARAMETER_ZULIP_EMAIL"},
+		),
+		createStringCliFlag(
+			"stream",
+			[]string{"st"},
+			"The Zulip stream to post the message to. Defaults to the channel",
+			[]string{"STREAM", "PLUGIN_STREAM", "PARAMETER_STREAM"},
+		),
+		createStringCliFlag(
+			"topic",
+			[]string{"tp"},
+			"Template of the Zulip topic to post the message to. Defaults to builds",
+			[]string{"TOPIC", "PLUGIN_TOPIC", "PARAMETER_TOPIC"},
+		),
+		createStringCliFlag(
+			"homeserver",
+			[]string{"hs"},
+			"URL of the Matrix homeserver, like https://matrix.example.org",
+			[]string{"HOMESERVER", "PLUGIN_HOMESERVER", "PARAMETER_HOMESERVER"},
+		),
+		createStringCliFlag(
+			"parse_mode",
+			[]string{"pm"},
+			"Telegram parse mode, HTML or MarkdownV2. Defaults to HTML",
+			[]string{"PARSE_MODE", "PLUGIN_PARSE_MODE", "PARAMETER_PARSE_MODE"},
+		),
+		createBoolCliFlag(
+			"silent_on_success",
+			[]string{"sos"},
+			"Flag to send messages of successful builds silently on Telegram",
+			[]string{"SILENT_ON_SUCCESS", "PLUGIN_SILENT_ON_SUCCESS", "PARAMETER_SILENT_ON_SUCCESS"},
+		),
+		createStringCliFlag(
+			"card",
+			[]string{"cd"},
+			"Markdown shown in the sidebar when the info icon of the message is clicked, on Mattermost",
+			[]string{"CARD", "PLUGIN_CARD", "PARAMETER_CARD"},
+		),
+		createStringCliFlag(
+			"priority",
+			[]string{"pr"},
+			"Mattermost message priority, standard, important or urgent. Derived from the build status by default",
+			[]string{"PRIORITY", "PLUGIN_PRIORITY", "PARAMETER_PRIORITY"},
+		),
+		createBoolCliFlag(
+			"requested_ack",
+			[]string{"ra"},
+			"Flag to request an acknowledgement of important and urgent messages, on Mattermost",
+			[]string{"REQUESTED_ACK", "PLUGIN_REQUESTED_ACK", "PARAMETER_REQUESTED_ACK"},
+		),
+		createStringCliFlag(
+			"default_branch",
+			[]string{"db"},
+			"The default branch of the repository, on which failures are urgent. Read from the CI environment by default",
+			[]string{"DEFAULT_BRANCH", "PLUGIN_DEFAULT_BRANCH", "PARAMETER_DEFAULT_BRANCH"},
+		),
+		createStringCliFlag(
+			"dedup_key",
+			[]string{"dk"},
+			"Template of the PagerDuty dedup key, that ties the resolve event to the incident. Defaults to the repository and branch",
+			[]string{"DEDUP_KEY", "PLUGIN_DEDUP_KEY", "PARAMETER_DEDUP_KEY"},
+		),
+		createStringCliFlag(
+			"source",
+			[]string{"src"},
+			"Source of the PagerDuty incident. Defaults to the dedup key",
+			[]string{"SOURCE", "PLUGIN_SOURCE", "PARAMETER_SOURCE"},
+		),
+		createStringCliFlag(
+			"severity",
+			[]string{"sev"},
+			"Severity of the PagerDuty incident, critical, error, warning or info. Derived from the branch by default",
+			[]string{"SEVERITY", "PLUGIN_SEVERITY", "PARAMETER_SEVERITY"},
+		),
+		createStringCliFlag(
+			"smtp_host",
+			[]string{"sh"},
+			"Host name of the SMTP server to send emails through",
+			[]string{"SMTP_HOST", "PLUGIN_SMTP_HOST", "PARAMETER_SMTP_HOST"},
+		),
+		createStringCliFlag(
+			"smtp_port",
+			[]string{"sp"},
+			"Port of the SMTP server. Defaults to 587",
+			[]string{"SMTP_PORT", "PLUGIN_SMTP_PORT", "PARAMETER_SMTP_PORT"},
+		),
+		createStringCliFlag(
+			"smtp_username",
+			[]string{"su"},
+			"User name to authenticate to the SMTP server with",
+			[]string{"SMTP_USERNAME", "PLUGIN_SMTP_USERNAME", "PARAMETER_SMTP_USERNAME"},
+		),
+		createStringCliFlag(
+			"smtp_password",
+			[]string{"spw"},
+			"Password to authenticate to the SMTP server with",
+			[]string{"SMTP_PASSWORD", "PLUGIN_SMTP_PASSWORD"},
+		),
+		createStringCliFlag(
+			"smtp_starttls",
+			[]string{"stls"},
+			"Flag to require STARTTLS before authenticating and sending the email. Defaults to true",
+			[]string{"SMTP_STARTTLS", "PLUGIN_SMTP_STARTTLS", "PARAMETER_SMTP_STARTTLS"},
+		),
+		createStringCliFlag(
+			"email_from",
+			[]string{"ef"},
+			"Sender address of the email",
+			[]string{"EMAIL_FROM", "PLUGIN_EMAIL_FROM", "PARAMETER_EMAIL_FROM"},
+		),
+		createStringCliFlag(
+			"email_to",
+			[]string{"et"},
+			"Comma separated recipient addresses of the email. Defaults to the channel",
+			[]string{"EMAIL_TO", "PLUGIN_EMAIL_TO", "PARAMETER_EMAIL_TO"},
+		),
+		createStringCliFlag(
+			"subject",
+			[]string{"sub"},
+			"Template of the email subject. Defaults to the title",
+			[]string{"SUBJECT", "PLUGIN_SUBJECT", "PARAMETER_SUBJECT"},
+		),
+		createStringCliFlag(
+			"method",
+			[]string{"mt"},
+			"HTTP method of the generic webhook request. Defaults to POST",
+			[]string{"METHOD", "PLUGIN_METHOD", "PARAMETER_METHOD"},
+		),
+		createStringCliFlag(
+			"headers",
+			[]string{"hd"},
+			"JSON or YAML map of headers of the generic webhook request. Header values use go templating",
+			[]string{"HEADERS", "PLUGIN_HEADERS", "PARAMETER_HEADERS"},
+		),
+		createStringCliFlag(
+			"body",
+			[]string{"bd"},
+			"Go template of the generic webhook request body. Defaults to the message as JSON",
+			[]string{"BODY", "PLUGIN_BODY", "PARAMETER_BODY"},
+		),
+		createStringCliFlag(
+			"body_file",
+			[]string{"bf"},
+			"Path of a file holding the go template of the generic webhook request body",
+			[]string{"BODY_FILE", "PLUGIN_BODY_FILE", "PARAMETER_BODY_FILE"},
+		),
+		createStringCliFlag(
+			"content_type",
+			[]string{"ct"},
+			"Content type of the generic webhook request. JSON bodies are validated before sending. Defaults to application/json",
+			[]string{"CONTENT_TYPE", "PLUGIN_CONTENT_TYPE", "PARAMETER_CONTENT_TYPE"},
+		),
+		createStringCliFlag(
+			"auth_username",
+			[]string{"au"},
+			"User name for basic authentication of the generic webhook request, with the token as the password. The token is sent as a bearer token otherwise",
+			[]string{"AUTH_USERNAME", "PLUGIN_AUTH_USERNAME", "PARAMETER_AUTH_USERNAME"},
+		),
 	}
 
 	err := app.Run(args)
@@ -68,26 +510,420 @@ func createStringCliFlag(name string, aliases []string, usage string, envVars []
 	}
 }
 
+// Creates a CLI parameter that can be repeated
+func createStringSliceCliFlag(name string, aliases []string, usage string, envVars []string) *cli.StringSliceFlag {
+	return &cli.StringSliceFlag{
+		Name:    name,
+		Aliases: aliases,
+		Usage:   usage,
+		EnvVars: envVars,
+	}
+}
+
+// Creates a Boolean CLI parameter
+func createBoolCliFlag(name string, aliases []string, usage string, envVars []string) *cli.BoolFlag {
+	return &cli.BoolFlag{
+		Name:    name,
+		Aliases: aliases,
+		Usage:   usage,
+		EnvVars: envVars,
+	}
+}
+
+// Creates an Integer CLI parameter
+func createIntCliFlag(name string, aliases []string, usage string, envVars []string, value int) *cli.IntFlag {
+	return &cli.IntFlag{
+		Name:    name,
+		Aliases: aliases,
+		Usage:   usage,
+		EnvVars: envVars,
+		Value:   value,
+	}
+}
+
+// Creates a Duration CLI parameter
+func createDurationCliFlag(name string, aliases []string, usage string, envVars []string, value time.Duration) *cli.DurationFlag {
+	return &cli.DurationFlag{
+		Name:    name,
+		Aliases: aliases,
+		Usage:   usage,
+		EnvVars: envVars,
+		Value:   value,
+	}
+}
+
 // Sends the input text to slack
 func run(context *cli.Context) error {
-	return slack.Notify(buildRequest(context))
+	slack.SetEnvFilter(slack.ParseEnvFilter(context.String("env_allowlist"), context.String("env_denylist")))
+	err := addSecrets(context)
+	if err != nil {
+		return err
+	}
+
+	err = slack.LoadTemplates(context.String("template_dir"))
+	if err != nil {
+		return err
+	}
+
+	targets, err := buildTargets(context)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		slack.AddSecrets(target.Secrets()...)
+	}
+	if len(targets) > 0 {
+		return runTargets(targets, context)
+	}
+
+	provider := context.String("provider")
+	if provider != "" && provider != notifier.DefaultProvider {
+		return runProvider(provider, context)
+	}
+
+	slackRequest, err := buildRequest(context, buildConfig(context))
+	if err != nil {
+		return err
+	}
+	tsFile := context.String("ts_file")
+
+	if context.Bool("update") {
+		slackRequest.Timestamp = context.String("ts")
+
+		if slackRequest.Timestamp == "" && tsFile != "" {
+			message, err := readMessageFile(tsFile)
+			if err != nil {
+				return err
+			}
+
+			if message.Timestamp != "" {
+				slackRequest.Channel = message.Channel
+				slackRequest.Timestamp = message.Timestamp
+			}
+		}
+
+		if slackRequest.Timestamp == "" {
+			log.Warn("No message to update, posting a new message")
+		}
+	}
+
+	response, err := post(buildClient(context), slackRequest, context)
+	if err != nil {
+		return err
+	}
+
+	if response.Timestamp != "" {
+		log.Info("Message posted to channel ", response.Channel, " with ts ", response.Timestamp)
+
+		if tsFile != "" {
+			return writeMessageFile(tsFile, response)
+		}
+	}
+
+	return nil
 }
 
-// Forms a Slack request from the supplied parameters
-func buildRequest(context *cli.Context) slack.SlackRequest {
-	slackRequest := slack.SlackRequest{}
-	slackRequest.Text = context.String("text")
-	slackRequest.Color = context.String("color")
-	slackRequest.Title = context.String("title")
-	slackRequest.Channel = context.String("channel")
-	slackRequest.Webhook = context.String("webhook")
+// Prints the template helper functions, with an example of each
+func listFunctions(context *cli.Context) error {
+	for _, function := range slack.HelperFunctions() {
+		fmt.Fprintf(context.App.Writer, "%s %s\n", function.Name, function.Usage)
+		fmt.Fprintf(context.App.Writer, "    %s\n", function.Description)
+		fmt.Fprintf(context.App.Writer, "    Example: %s\n", function.Example)
+		fmt.Fprintf(context.App.Writer, "    Output:  %s\n\n", strings.ReplaceAll(function.Output, "\n", "\n             "))
+	}
+
+	fmt.Fprintln(context.App.Writer, "The sprig functions are also available, as listed in https://masterminds.github.io/sprig/")
+	return nil
+}
+
+// Sends the message through a backend other than Slack
+func runProvider(provider string, context *cli.Context) error {
+	message, err := buildMessage(context)
+	if err != nil {
+		return err
+	}
+
+	backend, err := notifier.New(provider, buildConfig(context))
+	if err != nil {
+		return err
+	}
+
+	result, err := backend.Notify(context.Context, message)
+	if err != nil {
+		return err
+	}
 
-	return slackRequest
+	log.Info("Message sent to ", provider)
+	if result.Id != "" {
+		log.Info("Message posted to channel ", result.Channel, " with id ", result.Id)
+	}
+
+	return nil
 }
 
-// Logs the error and exits the application
+// Sends the message to each of the targets concurrently and fails as per
+// the failure policy
+func runTargets(targets []notifier.Target, context *cli.Context) error {
+	policy := context.String("failure_policy")
+	err := notifier.ValidateFailurePolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	if context.Bool("update") {
+		return errors.New("Updating a message is not supported with targets")
+	}
+
+	message, err := buildMessage(context)
+	if err != nil {
+		return err
+	}
+
+	client := buildClient(context)
+	threadStore := buildThreadStore(context)
+	results := notifier.FanOut(context.Context, targets, func(ctx gocontext.Context, target notifier.Target) (notifier.Result, error) {
+		config := target.Apply(buildConfig(context), context.String("provider"))
+		provider := target.Provider
+		if provider == "" {
+			provider = context.String("provider")
+		}
+
+		if provider != "" && provider != notifier.DefaultProvider {
+			backend, err := notifier.New(provider, config)
+			if err != nil {
+				return notifier.Result{}, err
+			}
+
+			return backend.Notify(ctx, message)
+		}
+
+		slackRequest, err := buildRequest(context, config)
+		if err != nil {
+			return notifier.Result{}, err
+		}
+
+		// Each target keeps its own threads, as the parent messages differ
+		threadKey := context.String("thread_key")
+		if threadKey != "" {
+			threadKey += "/" + target.Name
+		}
+
+		response, err := postWithStore(ctx, client, slackRequest, threadKey, threadStore)
+		return notifier.Result{Channel: response.Channel, Id: response.Timestamp}, err
+	})
+
+	return notifier.CheckResults(context.Context, policy, results)
+}
+
+// Registers the secrets to redact from messages and logs, namely the
+// secret environment variables, the webhook and tokens, and the values
+// matching the redact patterns
+func addSecrets(context *cli.Context) (err error) {
+	slack.AddSecretsFromEnv()
+	slack.AddSecrets(
+		context.String("webhook"),
+		context.String("token"),
+		context.String("rocketchat_token"),
+		context.String("smtp_password"),
+	)
+
+	patterns := context.String("redact_patterns")
+	if patterns == "" {
+		return
+	}
+
+	var patternList []string
+	if notifier.Decode(patterns, &patternList) != nil {
+		patternList = strings.Split(patterns, ",")
+	}
+
+	return slack.AddSecretPatterns(patternList...)
+}
+
+// Reads the targets from the targets list and the repeated target flag
+func buildTargets(context *cli.Context) (targets []notifier.Target, err error) {
+	if targetList := context.String("targets"); targetList != "" {
+		err = notifier.Decode(targetList, &targets)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, targetDocument := range context.StringSlice("target") {
+		target := notifier.Target{}
+		err = notifier.Decode(targetDocument, &target)
+		if err != nil {
+			return
+		}
+		targets = append(targets, target)
+	}
+
+	return
+}
+
+// Creates a Slack client from the supplied parameters
+func buildClient(context *cli.Context) *slack.Client {
+	options := []slack.Option{}
+	if timeout := context.Duration("timeout"); timeout > 0 {
+		options = append(options, slack.WithTimeout(timeout))
+	}
+
+	return slack.NewClient(options...)
+}
+
+// Posts the message, as a thread reply if a thread key is specified
+func post(client *slack.Client, slackRequest slack.SlackRequest, context *cli.Context) (slack.SlackResponse, error) {
+	return postWithStore(context.Context, client, slackRequest, context.String("thread_key"), buildThreadStore(context))
+}
+
+// Posts the message, as a thread reply within the store if a thread key is
+// specified
+func postWithStore(ctx gocontext.Context, client *slack.Client, slackRequest slack.SlackRequest, threadKey string, threadStore slack.ThreadStore) (slack.SlackResponse, error) {
+	if threadKey == "" {
+		return client.Send(ctx, slackRequest)
+	}
+
+	return client.SendInThread(ctx, slackRequest, threadKey, threadStore)
+}
+
+// Creates the store that maps thread keys to parent messages
+func buildThreadStore(context *cli.Context) slack.ThreadStore {
+	threadFile := context.String("thread_file")
+	if threadFile == "" {
+		threadFile = defaultThreadFile
+	}
+
+	return slack.NewFileThreadStore(threadFile)
+}
+
+// Reads the identifiers of a previously posted message. A missing file
+// means that no message has been posted yet
+func readMessageFile(path string) (message slack.SlackResponse, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return message, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &message)
+	return
+}
+
+// Saves the identifiers of the posted message, so that a later step can
+// update it
+func writeMessageFile(path string, message slack.SlackResponse) error {
+	data, _ := json.Marshal(message)
+	return os.WriteFile(path, data, 0644)
+}
+
+// Forms a Slack request to the destination in the config from the supplied
+// parameters
+func buildRequest(context *cli.Context, config notifier.Config) (slackRequest slack.SlackRequest, err error) {
+	message, err := buildMessage(context)
+	if err != nil {
+		return
+	}
+
+	slackRequest = notifier.ToSlackRequest(config, message)
+	slackRequest.ReplyBroadcast = context.Bool("reply_broadcast")
+	slackRequest.BlocksTemplate = context.String("blocks")
+	slackRequest.ColorBar = slackRequest.ColorBar || context.Bool("color_bar")
+	slackRequest.Alias = context.String("alias")
+	slackRequest.Avatar = context.String("avatar")
+	slackRequest.Emoji = context.String("emoji")
+	slackRequest.TitleLink = context.String("title_link")
+	slackRequest.Collapsed = context.Bool("collapsed")
+	slackRequest.ImageUrl = context.String("image_url")
+	slackRequest.RocketChatUrl = context.String("rocketchat_url")
+	slackRequest.RocketChatUserId = context.String("rocketchat_user_id")
+	slackRequest.RocketChatToken = context.String("rocketchat_token")
+
+	return
+}
+
+// Forms a backend neutral message from the supplied parameters
+func buildMessage(context *cli.Context) (message notifier.Message, err error) {
+	message.Text, err = buildText(context)
+	if err != nil {
+		return
+	}
+	message.Color = context.String("color")
+	message.Title = context.String("title")
+
+	if fields := context.String("fields"); fields != "" {
+		err = notifier.Decode(fields, &message.Fields)
+		if err != nil {
+			return
+		}
+	}
+
+	if links := context.String("links"); links != "" {
+		err = notifier.Decode(links, &message.Links)
+	}
+
+	return
+}
+
+// Reads the message content from the template file if specified. Without
+// a text, the template named after the build status is used from the
+// template directory
+func buildText(context *cli.Context) (string, error) {
+	if templateFile := context.String("template_file"); templateFile != "" {
+		data, err := os.ReadFile(templateFile)
+		return string(data), err
+	}
+
+	text := context.String("text")
+	if text == "" {
+		text = slack.StatusTemplate(slack.BuildStatus())
+	}
+
+	return text, nil
+}
+
+// Forms the config of the notification backend from the supplied parameters
+func buildConfig(context *cli.Context) notifier.Config {
+	config := notifier.Config{
+		Webhook:  context.String("webhook"),
+		Token:    context.String("token"),
+		Channel:  context.String("channel"),
+		Settings: make(map[string]string),
+		Retry: notifier.RetryPolicy{
+			MaxRetries:     context.Int("retries"),
+			InitialBackoff: context.Duration("retry_backoff"),
+			MaxBackoff:     context.Duration("retry_max_backoff"),
+		},
+	}
+
+	if timeout := context.Duration("timeout"); timeout > 0 {
+		config.HttpClient = &http.Client{Timeout: timeout}
+	}
+
+	// Only the specified settings are passed on, so that the backends can
+	// tell an unset flag from a false one
+	for _, setting := range providerSettings {
+		if context.IsSet(setting) {
+			config.Settings[setting] = context.String(setting)
+		}
+	}
+
+	return config
+}
+
+// Logs the error and exits the application. Includes the reason given by
+// the backend when the delivery failed
 func handleError(err error) {
 	if err != nil {
+		var deliveryError *notifier.DeliveryError
+		if errors.As(err, &deliveryError) {
+			log.WithFields(log.Fields{
+				"status":    deliveryError.StatusCode,
+				"reason":    deliveryError.Reason,
+				"retryable": deliveryError.Retryable,
+			}).Fatal(err)
+		}
+
 		log.Fatal(err)
 	}
 }
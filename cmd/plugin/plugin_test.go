@@ -4,14 +4,19 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/devatherock/simple-slack/pkg/slack"
 	"github.com/devatherock/simple-slack/test/helper"
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli/v2"
@@ -136,3 +141,502 @@ func TestRunSlackCallFailed(test *testing.T) {
 		assert.Equal(test, "general", jsonRequest["channel"])
 	}
 }
+
+func TestRunWithBlocks(test *testing.T) {
+	// Test HTTP server
+	var capturedRequest []byte
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = ioutil.ReadAll(request.Body)
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"success":true}`)
+	}))
+	defer testServer.Close()
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("text", "Build failed!", "")
+	set.String("color", "red", "")
+	set.String("blocks", `[{"type":"section","text":{"type":"mrkdwn","text":"*Build failed!*"}}]`, "")
+	set.Bool("color_bar", true, "")
+	set.String("webhook", testServer.URL, "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+
+	// Verify no error
+	assert.Nil(test, actual)
+
+	// Verify request
+	jsonRequest := make(map[string]interface{})
+	json.Unmarshal(capturedRequest, &jsonRequest)
+	assert.Equal(test, 1, len(jsonRequest))
+
+	attachments := jsonRequest["attachments"].([]interface{})
+	attachment := attachments[0].(map[string]interface{})
+	blocks := attachment["blocks"].([]interface{})
+	block := blocks[0].(map[string]interface{})
+
+	assert.Equal(test, 1, len(attachments))
+	assert.Equal(test, "red", attachment["color"])
+	assert.Equal(test, "Build failed!", attachment["fallback"])
+	assert.Equal(test, 1, len(blocks))
+	assert.Equal(test, "section", block["type"])
+	assert.Equal(test, "*Build failed!*", block["text"].(map[string]interface{})["text"])
+}
+
+func TestRunWithToken(test *testing.T) {
+	// Test HTTP server
+	var capturedRequest []byte
+	var authorization string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = ioutil.ReadAll(request.Body)
+		authorization = request.Header.Get("Authorization")
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"ok":true,"channel":"C1234","ts":"1503435956.000247"}`)
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "SLACK_API_HOST", testServer.URL)
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("text", "Build failed!", "")
+	set.String("color", "red", "")
+	set.String("title", "Build notification", "")
+	set.String("channel", "general", "")
+	set.String("token", "xoxb-secret", "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+
+	// Verify no error
+	assert.Nil(test, actual)
+	assert.Equal(test, "Bearer xoxb-secret", authorization)
+
+	// Verify request
+	helper.VerifySlackRequest(test, capturedRequest, map[string]string{
+		"text":  "Build failed!",
+		"color": "red",
+		"title": "Build notification",
+	})
+}
+
+func TestRunUpdateWithTsFile(test *testing.T) {
+	// Test HTTP server
+	var capturedRequests []map[string]interface{}
+	var capturedPaths []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		jsonRequest := make(map[string]interface{})
+		json.Unmarshal(body, &jsonRequest)
+		capturedRequests = append(capturedRequests, jsonRequest)
+		capturedPaths = append(capturedPaths, request.URL.Path)
+
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"ok":true,"channel":"C1234","ts":"1503435956.000247"}`)
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "SLACK_API_HOST", testServer.URL)
+	tsFile := filepath.Join(test.TempDir(), "slack-message.json")
+
+	for _, text := range []string{"Build running", "Build succeeded!"} {
+		set := flag.NewFlagSet("test", 0)
+		set.String("text", text, "")
+		set.String("channel", "general", "")
+		set.String("token", "xoxb-secret", "")
+		set.Bool("update", true, "")
+		set.String("ts_file", tsFile, "")
+
+		context := cli.NewContext(nil, set, nil)
+		actual := run(context)
+
+		assert.Nil(test, actual)
+	}
+
+	// Verify the first run posts and the second one updates
+	assert.Equal(test, []string{"/api/chat.postMessage", "/api/chat.update"}, capturedPaths)
+	assert.Equal(test, "general", capturedRequests[0]["channel"])
+	assert.Nil(test, capturedRequests[0]["ts"])
+	assert.Equal(test, "C1234", capturedRequests[1]["channel"])
+	assert.Equal(test, "1503435956.000247", capturedRequests[1]["ts"])
+
+	// Verify the saved message
+	data, _ := ioutil.ReadFile(tsFile)
+	assert.JSONEq(test, `{"channel":"C1234","ts":"1503435956.000247"}`, string(data))
+}
+
+func TestRunWithFieldsAndLinks(test *testing.T) {
+	// Test HTTP server
+	var capturedRequest []byte
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = ioutil.ReadAll(request.Body)
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"success":true}`)
+	}))
+	defer testServer.Close()
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("text", "Build failed!", "")
+	set.String("color", "red", "")
+	set.String("fields", `[{"title":"Branch","value":"master","short":true}]`, "")
+	set.String("links", "- text: Open build\n  url: https://someurl", "")
+	set.String("webhook", testServer.URL, "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+
+	// Verify no error
+	assert.Nil(test, actual)
+
+	// Verify request
+	jsonRequest := make(map[string]interface{})
+	json.Unmarshal(capturedRequest, &jsonRequest)
+
+	attachment := jsonRequest["attachments"].([]interface{})[0].(map[string]interface{})
+	blocks := attachment["blocks"].([]interface{})
+
+	assert.Equal(test, "red", attachment["color"])
+	assert.Equal(test, 3, len(blocks))
+	assert.Equal(test, "*Branch*\nmaster", blocks[1].(map[string]interface{})["fields"].([]interface{})[0].(map[string]interface{})["text"])
+
+	button := blocks[2].(map[string]interface{})["elements"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(test, "https://someurl", button["url"])
+}
+
+func TestRunUnknownProvider(test *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("text", "Build failed!", "")
+	set.String("provider", "carrier-pigeon", "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+
+	assert.Equal(test, "Unknown provider carrier-pigeon", actual.Error())
+}
+
+func TestBuildConfigSettings(test *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("username", "", "")
+	set.String("priority", "", "")
+	set.Bool("requested_ack", false, "")
+	set.Bool("silent_on_success", false, "")
+	set.Set("username", "CI")
+	set.Set("requested_ack", "false")
+
+	context := cli.NewContext(nil, set, nil)
+	config := buildConfig(context)
+
+	assert.Equal(test, map[string]string{"username": "CI", "requested_ack": "false"}, config.Settings)
+}
+
+func TestRunWithTargets(test *testing.T) {
+	cases := []struct {
+		policy   string
+		expected string
+	}{
+		{"", "1 of 2 targets failed. ops: HTTP request to Slack failed: invalid_payload"},
+		{"any", "1 of 2 targets failed. ops: HTTP request to Slack failed: invalid_payload"},
+		{"all", ""},
+		{"never", ""},
+	}
+
+	for _, data := range cases {
+		test.Run(data.policy, func(test *testing.T) {
+			// Test HTTP servers
+			var capturedRequest []byte
+			testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				capturedRequest, _ = ioutil.ReadAll(request.Body)
+				fmt.Fprint(writer, "ok")
+			}))
+			defer testServer.Close()
+
+			failingServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(writer, "invalid_payload")
+			}))
+			defer failingServer.Close()
+
+			set := flag.NewFlagSet("test", 0)
+			set.String("text", "Released!", "")
+			set.String("failure_policy", data.policy, "")
+			set.String("targets", fmt.Sprintf("- webhook: %s\n  channel: '#releases'\n- name: ops\n  webhook: %s", testServer.URL, failingServer.URL), "")
+
+			context := cli.NewContext(nil, set, nil)
+			actual := run(context)
+
+			if data.expected == "" {
+				assert.Nil(test, actual)
+			} else {
+				assert.Equal(test, data.expected, actual.Error())
+			}
+
+			jsonRequest := make(map[string]interface{})
+			json.Unmarshal(capturedRequest, &jsonRequest)
+			assert.Equal(test, "#releases", jsonRequest["channel"])
+		})
+	}
+}
+
+func TestRunWithMixedTargets(test *testing.T) {
+	// Test HTTP servers
+	var mutex sync.Mutex
+	var slackPaths, slackAuthorization, genericAuthorization []string
+	slackServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		slackPaths = append(slackPaths, request.URL.Path)
+		slackAuthorization = append(slackAuthorization, request.Header.Get("Authorization"))
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"ok":true,"channel":"C1234","ts":"1503435956.000247"}`)
+	}))
+	defer slackServer.Close()
+	helper.SetEnvironmentVariable(test, "SLACK_API_HOST", slackServer.URL)
+
+	genericServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		genericAuthorization = append(genericAuthorization, request.Header.Get("Authorization"))
+	}))
+	defer genericServer.Close()
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("text", "Released!", "")
+	set.String("channel", "general", "")
+	set.String("webhook", slackServer.URL+"/webhook", "")
+	set.String("token", "xoxb-secret", "")
+	set.String("targets", fmt.Sprintf(`[{"name": "slack"}, {"name": "hook", "provider": "generic", "webhook": "%s"}, {"name": "teams", "provider": "teams"}]`, genericServer.URL), "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+
+	// Only the Slack target uses the common webhook and token
+	assert.Equal(test, "1 of 3 targets failed. teams: Webhook is required to post to Teams", actual.Error())
+	assert.Equal(test, []string{"/api/chat.postMessage"}, slackPaths)
+	assert.Equal(test, []string{"Bearer xoxb-secret"}, slackAuthorization)
+	assert.Equal(test, []string{""}, genericAuthorization)
+}
+
+func TestRunWithTargetsAllFailed(test *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("text", "Released!", "")
+	set.String("failure_policy", "all", "")
+	set.String("targets", `[{"provider": "carrier-pigeon"}, {"provider": "teams"}]`, "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+
+	assert.Equal(test, "2 of 2 targets failed. target 1: Unknown provider carrier-pigeon; target 2: Webhook is required to post to Teams", actual.Error())
+}
+
+func TestRunWithTargetsInvalidPolicy(test *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("failure_policy", "sometimes", "")
+	set.String("targets", `[{"webhook": "https://someurl"}]`, "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+
+	assert.Equal(test, "Unknown failure policy sometimes. Should be one of any, all or never", actual.Error())
+}
+
+func TestRunAppWithRepeatedTargets(test *testing.T) {
+	// Test HTTP server
+	capturedRequests := make(chan []byte, 2)
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ := ioutil.ReadAll(request.Body)
+		capturedRequests <- capturedRequest
+		fmt.Fprint(writer, "ok")
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "RELEASE_WEBHOOK", testServer.URL)
+
+	runApp([]string{
+		"-x",
+		"--text", "Released!",
+		"--target", `{"webhook_env": "RELEASE_WEBHOOK", "channel": "#releases"}`,
+		"--target", fmt.Sprintf(`{"webhook": "%s", "channel": "#general"}`, testServer.URL),
+	})
+
+	channels := []interface{}{}
+	for range 2 {
+		jsonRequest := make(map[string]interface{})
+		json.Unmarshal(<-capturedRequests, &jsonRequest)
+		channels = append(channels, jsonRequest["channel"])
+	}
+	assert.ElementsMatch(test, []interface{}{"#releases", "#general"}, channels)
+}
+
+func TestRunWithEnvDenylist(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "RELEASE_NOTES", "Fixed bugs")
+	helper.SetEnvironmentVariable(test, "AWS_ACCESS_KEY", "secret")
+
+	// Test HTTP server
+	var capturedRequest []byte
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = ioutil.ReadAll(request.Body)
+		fmt.Fprint(writer, "ok")
+	}))
+	defer testServer.Close()
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("text", `{{.ReleaseNotes}} {{env "AWS_ACCESS_KEY"}}{{.AwsAccessKey}}`, "")
+	set.String("webhook", testServer.URL, "")
+	set.String("env_denylist", "*_KEY", "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+	test.Cleanup(func() { slack.SetEnvFilter(slack.EnvFilter{}) })
+
+	assert.Nil(test, actual)
+	assert.JSONEq(test, `{"attachments":[{"color":"#cfd3d7","text":"Fixed bugs <no value>"}]}`, string(capturedRequest))
+}
+
+func TestRunWithRedaction(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DEPLOY_TOKEN", "deploytoken")
+
+	// Test HTTP server
+	var capturedRequest []byte
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = ioutil.ReadAll(request.Body)
+		fmt.Fprint(writer, "ok")
+	}))
+	defer testServer.Close()
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("text", "Posted to "+testServer.URL+" with deploytoken and ghp_abc123", "")
+	set.String("webhook", testServer.URL, "")
+	set.String("redact_patterns", `["ghp_[A-Za-z0-9]+"]`, "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+
+	assert.Nil(test, actual)
+	assert.JSONEq(test, `{"attachments":[{"color":"#cfd3d7","text":"Posted to **** with **** and ****"}]}`, string(capturedRequest))
+}
+
+func TestRunWithInvalidRedactPattern(test *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("text", "Build completed", "")
+	set.String("webhook", "https://hooks.slack.com/services/T0/B0/X0", "")
+	set.String("redact_patterns", "ghp_[", "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+
+	assert.NotNil(test, actual)
+}
+
+func TestRunWithTemplateDir(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "failure")
+
+	templateDir := test.TempDir()
+	os.WriteFile(filepath.Join(templateDir, "footer.tmpl"), []byte(`in {{.Repo.Name}}`), 0644)
+	os.WriteFile(filepath.Join(templateDir, "success.tmpl"), []byte(`Passed {{template "footer" .}}`), 0644)
+	os.WriteFile(filepath.Join(templateDir, "failure.tmpl"), []byte(`Failed {{template "footer" .}}`), 0644)
+	test.Cleanup(func() { slack.LoadTemplates("") })
+
+	cases := []struct {
+		text     string
+		expected string
+	}{
+		{
+			"",
+			"Failed in octocat/hello-world",
+		},
+		{
+			`{{include "success" . | upper}}`,
+			"PASSED IN OCTOCAT/HELLO-WORLD",
+		},
+	}
+
+	for _, data := range cases {
+		// Test HTTP server
+		var capturedRequest []byte
+		testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			capturedRequest, _ = ioutil.ReadAll(request.Body)
+			fmt.Fprint(writer, "ok")
+		}))
+
+		set := flag.NewFlagSet("test", 0)
+		set.String("text", data.text, "")
+		set.String("template_dir", templateDir, "")
+		set.String("webhook", testServer.URL, "")
+
+		context := cli.NewContext(nil, set, nil)
+		actual := run(context)
+		testServer.Close()
+
+		assert.Nil(test, actual)
+		assert.JSONEq(test, `{"attachments":[{"color":"#a1040c","text":"`+data.expected+`"}]}`, string(capturedRequest))
+	}
+}
+
+func TestRunWithTemplateFile(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+
+	templateFile := filepath.Join(test.TempDir(), "message.tmpl")
+	os.WriteFile(templateFile, []byte(`Deployed {{.Repo.Name}}`), 0644)
+
+	// Test HTTP server
+	var capturedRequest []byte
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = ioutil.ReadAll(request.Body)
+		fmt.Fprint(writer, "ok")
+	}))
+	defer testServer.Close()
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("template_file", templateFile, "")
+	set.String("webhook", testServer.URL, "")
+
+	context := cli.NewContext(nil, set, nil)
+	actual := run(context)
+
+	assert.Nil(test, actual)
+	assert.JSONEq(test, `{"attachments":[{"color":"#cfd3d7","text":"Deployed octocat/hello-world"}]}`, string(capturedRequest))
+}
+
+func TestRunWithMissingTemplates(test *testing.T) {
+	cases := []struct {
+		flag     string
+		expected string
+	}{
+		{
+			"template_file",
+			"no such file or directory",
+		},
+		{
+			"template_dir",
+			"No .tmpl files found in ",
+		},
+	}
+
+	for _, data := range cases {
+		set := flag.NewFlagSet("test", 0)
+		set.String(data.flag, filepath.Join(test.TempDir(), "missing"), "")
+		set.String("webhook", "https://hooks.slack.com/services/T0/B0/X0", "")
+
+		context := cli.NewContext(nil, set, nil)
+		actual := run(context)
+
+		assert.NotNil(test, actual)
+		assert.Contains(test, actual.Error(), data.expected)
+	}
+}
+
+func TestListFunctions(test *testing.T) {
+	buffer := new(bytes.Buffer)
+	app := cli.NewApp()
+	app.Writer = buffer
+
+	context := cli.NewContext(app, flag.NewFlagSet("test", 0), nil)
+	actual := listFunctions(context)
+
+	assert.Nil(test, actual)
+	for _, function := range slack.HelperFunctions() {
+		assert.Contains(test, buffer.String(), function.Name+" "+function.Usage+"\n")
+		assert.Contains(test, buffer.String(), "Example: "+function.Example+"\n")
+	}
+	assert.Contains(test, buffer.String(), "Output:  <https://ci.example.com/builds/42|Build #42>\n")
+	assert.Contains(test, buffer.String(), "https://masterminds.github.io/sprig/")
+}
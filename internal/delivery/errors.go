@@ -0,0 +1,144 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devatherock/simple-slack/internal/textutil"
+)
+
+// Limits how much of an error response body is read
+const maxErrorBodyLength int = 1024
+
+// Limits how much of a plain text error response is kept as the reason
+const maxReasonLength int = 256
+
+// Failed delivery of a message to a backend. Use errors.As to inspect it
+type DeliveryError struct {
+	Provider   string        // Name of the backend, like Slack or Teams
+	Host       string        // Host the message was sent to, when it names the destination better than the backend, like an SMTP server
+	Protocol   string        // Protocol used to reach the backend, empty for HTTP
+	StatusCode int           // HTTP status or other reply code of the response, 0 when no response was received
+	Reason     string        // Reason given by the backend, like invalid_payload or channel_not_found
+	Retryable  bool          // Whether sending the message again might succeed
+	RetryAfter time.Duration // Delay requested by the backend before retrying
+	Err        error         // Underlying error when no response was received
+}
+
+func (deliveryError *DeliveryError) Error() string {
+	destination, protocol := deliveryError.Provider, "HTTP"
+	if deliveryError.Host != "" {
+		destination = deliveryError.Host
+	}
+	if deliveryError.Protocol != "" {
+		protocol = deliveryError.Protocol
+	}
+
+	if deliveryError.Err != nil {
+		return protocol + " request to " + destination + " failed: " + deliveryError.Err.Error()
+	}
+
+	// Web APIs like Slack's report failures with a 200 status
+	if deliveryError.StatusCode == http.StatusOK && protocol == "HTTP" {
+		return destination + " API call failed: " + deliveryError.Reason
+	}
+
+	message := protocol + " request to " + destination + " failed"
+	if deliveryError.Reason != "" {
+		message += ": " + deliveryError.Reason
+	}
+
+	return message
+}
+
+func (deliveryError *DeliveryError) Unwrap() error {
+	return deliveryError.Err
+}
+
+// Creates an error for a request that didn't get a response, like a
+// connection failure or a timeout. The URL of the request is reduced to its
+// host, as webhooks and some APIs carry their secret in the path
+func NetworkError(provider string, err error) *DeliveryError {
+	return &DeliveryError{Provider: provider, Err: hideUrlPath(err), Retryable: true}
+}
+
+// Creates an error from a failed HTTP response. Rate limits and server
+// errors are retryable, while other client errors like an invalid payload
+// are not
+func ResponseError(provider string, res *http.Response) *DeliveryError {
+	deliveryError, _ := ReadResponseError(provider, res)
+	return deliveryError
+}
+
+// Form of ResponseError that also returns the start of the body, for
+// backends that give more details within it, like the delay on rate limits
+func ReadResponseError(provider string, res *http.Response) (*DeliveryError, []byte) {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, int64(maxErrorBodyLength)))
+	deliveryError := &DeliveryError{
+		Provider:   provider,
+		StatusCode: res.StatusCode,
+		Reason:     readReason(body),
+	}
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		deliveryError.Retryable = true
+		deliveryError.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"))
+	} else if res.StatusCode >= 500 {
+		deliveryError.Retryable = true
+	}
+
+	return deliveryError, body
+}
+
+// Removes the path and query from the URL of a failed request
+func hideUrlPath(err error) error {
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		requestUrl, parseErr := url.Parse(urlError.URL)
+		if parseErr == nil && requestUrl.Host != "" {
+			urlError.URL = requestUrl.Scheme + "://" + requestUrl.Host
+		} else {
+			urlError.URL = "<url>"
+		}
+	}
+
+	return err
+}
+
+// Extracts the reason from an error response. JSON APIs like Slack's give
+// it in an error field, while webhooks usually respond with plain text
+func readReason(body []byte) string {
+	errorResponse := struct {
+		Error string `json:"error"`
+	}{}
+	if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Error != "" {
+		return errorResponse.Error
+	}
+
+	return textutil.Truncate(strings.TrimSpace(string(body)), maxReasonLength)
+}
+
+// Reads the Retry-After header, which holds either seconds or a date
+func parseRetryAfter(retryAfter string) time.Duration {
+	if retryAfter == "" {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(retryAfter)
+	if err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	retryTime, err := http.ParseTime(retryAfter)
+	if err == nil {
+		return time.Until(retryTime)
+	}
+
+	return 0
+}
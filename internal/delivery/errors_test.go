@@ -0,0 +1,56 @@
+//go:build test
+// +build test
+
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkError(test *testing.T) {
+	err := NetworkError("Teams", &url.Error{Op: "Post", URL: "https://example.com/webhook/secret?key=value", Err: errors.New("EOF")})
+
+	assert.True(test, err.Retryable)
+	assert.Equal(test, `HTTP request to Teams failed: Post "https://example.com": EOF`, err.Error())
+}
+
+func TestParseRetryAfter(test *testing.T) {
+	cases := []struct {
+		retryAfter string
+		expected   time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"invalid", 0},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, parseRetryAfter(data.retryAfter))
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	actual := parseRetryAfter(future)
+	assert.Greater(test, actual, 50*time.Second)
+}
+
+func TestDeliveryErrorOfOtherProvider(test *testing.T) {
+	cases := []struct {
+		deliveryError DeliveryError
+		expected      string
+	}{
+		{DeliveryError{Provider: "Teams", StatusCode: 400, Reason: "Bad payload"}, "HTTP request to Teams failed: Bad payload"},
+		{DeliveryError{Provider: "Zulip", StatusCode: 200, Reason: "Stream does not exist"}, "Zulip API call failed: Stream does not exist"},
+		{DeliveryError{Provider: "Teams", Err: errors.New("timeout")}, "HTTP request to Teams failed: timeout"},
+		{DeliveryError{Provider: "Email", Host: "smtp.example.com", Protocol: "SMTP", StatusCode: 550, Reason: "Mailbox unavailable"}, "SMTP request to smtp.example.com failed: Mailbox unavailable"},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, data.deliveryError.Error())
+	}
+}
@@ -0,0 +1,86 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Waits for the delay or until the context is cancelled. Replaced in tests
+// to avoid waiting
+var Sleep = func(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Decides how failed deliveries are retried. The zero value disables retries
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Retries the send function as per the policy, until it succeeds, fails with
+// a permanent error, the retries are exhausted or the context is cancelled.
+// Only a DeliveryError marked as retryable is retried
+func Retry(ctx context.Context, policy RetryPolicy, logger log.FieldLogger, send func() error) error {
+	// Lets the log formatter redact the secrets of the context
+	if contextLogger, ok := logger.(interface {
+		WithContext(context.Context) *log.Entry
+	}); ok {
+		logger = contextLogger.WithContext(ctx)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := send()
+		if err == nil || attempt >= policy.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		var deliveryError *DeliveryError
+		if !errors.As(err, &deliveryError) || !deliveryError.Retryable {
+			return err
+		}
+
+		delay := policy.backoff(attempt, deliveryError.RetryAfter)
+		logger.Warn("Request failed, retrying in ", delay, ": ", err)
+		if Sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// Computes the delay before the next attempt. The delay requested by the
+// backend is honoured, up to the maximum backoff, so that a long Retry-After
+// can't stall the build. Otherwise, the delay grows exponentially with
+// jitter added, to avoid retrying in lockstep with other clients
+func (policy RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if policy.MaxBackoff > 0 && retryAfter > policy.MaxBackoff {
+			return policy.MaxBackoff
+		}
+
+		return retryAfter
+	}
+
+	delay := policy.InitialBackoff << attempt
+	if delay <= 0 || (policy.MaxBackoff > 0 && delay > policy.MaxBackoff) {
+		delay = policy.MaxBackoff
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,81 @@
+//go:build test
+// +build test
+
+package delivery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(test *testing.T) {
+	delays := []time.Duration{}
+	originalSleep := Sleep
+	Sleep = func(ctx context.Context, delay time.Duration) error {
+		delays = append(delays, delay)
+		return nil
+	}
+	test.Cleanup(func() { Sleep = originalSleep })
+
+	cases := []struct {
+		errs             []error
+		expectedAttempts int
+	}{
+		{[]error{&DeliveryError{Retryable: true}, nil}, 2},
+		{[]error{&DeliveryError{Retryable: true}, &DeliveryError{Retryable: true}, &DeliveryError{Retryable: true}}, 3},
+		{[]error{&DeliveryError{StatusCode: 400}, nil}, 1},
+		{[]error{errors.New("invalid payload"), nil}, 1},
+	}
+
+	for _, data := range cases {
+		attempts := 0
+		err := Retry(context.Background(), RetryPolicy{MaxRetries: 2, InitialBackoff: time.Second}, log.StandardLogger(), func() error {
+			attempts++
+			return data.errs[attempts-1]
+		})
+
+		assert.Equal(test, data.expectedAttempts, attempts)
+		assert.Equal(test, data.errs[attempts-1], err)
+	}
+
+	assert.Equal(test, 3, len(delays))
+}
+
+func TestBackoff(test *testing.T) {
+	policy := RetryPolicy{
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+	}
+
+	cases := []struct {
+		attempt  int
+		minDelay time.Duration
+		maxDelay time.Duration
+	}{
+		{0, 500 * time.Millisecond, time.Second},
+		{1, time.Second, 2 * time.Second},
+		{2, 2 * time.Second, 4 * time.Second},
+		{3, 2500 * time.Millisecond, 5 * time.Second},
+		{40, 2500 * time.Millisecond, 5 * time.Second},
+	}
+
+	for _, data := range cases {
+		actual := policy.backoff(data.attempt, 0)
+
+		assert.GreaterOrEqual(test, actual, data.minDelay)
+		assert.LessOrEqual(test, actual, data.maxDelay)
+	}
+
+	// Delay requested by the backend takes precedence, up to the maximum
+	assert.Equal(test, 3*time.Second, policy.backoff(0, 3*time.Second))
+	assert.Equal(test, 5*time.Second, policy.backoff(0, 20*time.Second))
+	assert.Equal(test, 5*time.Second, policy.backoff(0, 24*time.Hour))
+	assert.Equal(test, 20*time.Second, RetryPolicy{InitialBackoff: time.Second}.backoff(0, 20*time.Second))
+	assert.Equal(test, time.Duration(0), RetryPolicy{}.backoff(0, 0))
+}
@@ -0,0 +1,18 @@
+package textutil
+
+import (
+	"unicode/utf8"
+)
+
+// Shortens the text to the limit, ending it with an ellipsis when cut. Counts
+// characters rather than bytes, so that multibyte characters aren't split
+func Truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	if limit <= 0 {
+		return ""
+	}
+
+	return string([]rune(text)[:limit-1]) + "…"
+}
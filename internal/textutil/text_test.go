@@ -0,0 +1,27 @@
+//go:build test
+// +build test
+
+package textutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncate(test *testing.T) {
+	cases := []struct {
+		text     string
+		limit    int
+		expected string
+	}{
+		{"Fixed the login page", 20, "Fixed the login page"},
+		{"Fixed the login page", 12, "Fixed the l…"},
+		{"Déployé ✅", 8, "Déployé…"},
+		{"Fixed", 0, ""},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, Truncate(data.text, data.limit))
+	}
+}
@@ -0,0 +1,185 @@
+package ci
+
+import (
+	"os"
+)
+
+// Normalized build statuses
+const (
+	statusSuccess string = "success"
+	statusFailure string = "failure"
+)
+
+// CI neutral details of the build, read from the environment of the CI
+// system it runs on
+type Context struct {
+	System string // Name of the CI system, like drone. Empty when not running on a known CI system
+	Build  Build
+	Commit Commit
+	Repo   Repo
+	Branch string // Branch being built
+	Tag    string // Tag being built, for tag events
+}
+
+type Build struct {
+	Number   string
+	Status   string // Normalized to success or failure. Empty when the status is unknown
+	Link     string // URL of the build in the CI system
+	Event    string // Event that triggered the build, like push or tag
+	Started  string // Start time of the build, as a Unix timestamp
+	Finished string // Finish time of the build, as a Unix timestamp
+}
+
+type Commit struct {
+	Sha         string
+	Message     string
+	Author      string
+	AuthorEmail string
+	Link        string // URL of the commit or the compare view
+}
+
+type Repo struct {
+	Name          string // Full name of the repository, like octocat/hello-world
+	Owner         string
+	Link          string
+	DefaultBranch string
+}
+
+// Reads the build details from the environment of a CI system
+type adapter struct {
+	system string
+	detect string // Environment variable set to true by the CI system
+	read   func(getenv func(string) string) Context
+}
+
+var adapters = []adapter{
+	{"drone", "DRONE", readDrone},
+	{"vela", "VELA", readVela},
+	{"circleci", "CIRCLECI", readCircleCi},
+}
+
+// Reads the details of the build from the environment of the CI system
+// it runs on
+func Current() Context {
+	return CurrentWith(os.Getenv)
+}
+
+// Reads the details of the build through the getenv function, which can
+// hide some of the environment variables. The CI system is detected from
+// the environment as is
+func CurrentWith(getenv func(string) string) Context {
+	for _, adapter := range adapters {
+		if os.Getenv(adapter.detect) == "true" {
+			context := adapter.read(getenv)
+			context.System = adapter.system
+			return context
+		}
+	}
+
+	return Context{}
+}
+
+func readDrone(getenv func(string) string) Context {
+	status := ""
+	switch getenv("DRONE_BUILD_STATUS") {
+	case "success":
+		status = statusSuccess
+	case "failure", "error", "killed":
+		status = statusFailure
+	}
+
+	return Context{
+		Build: Build{
+			Number:   getenv("DRONE_BUILD_NUMBER"),
+			Status:   status,
+			Link:     getenv("DRONE_BUILD_LINK"),
+			Event:    getenv("DRONE_BUILD_EVENT"),
+			Started:  getenv("DRONE_BUILD_STARTED"),
+			Finished: getenv("DRONE_BUILD_FINISHED"),
+		},
+		Commit: Commit{
+			Sha:         getenv("DRONE_COMMIT_SHA"),
+			Message:     getenv("DRONE_COMMIT_MESSAGE"),
+			Author:      getenv("DRONE_COMMIT_AUTHOR"),
+			AuthorEmail: getenv("DRONE_COMMIT_AUTHOR_EMAIL"),
+			Link:        getenv("DRONE_COMMIT_LINK"),
+		},
+		Repo: Repo{
+			Name:          getenv("DRONE_REPO"),
+			Owner:         getenv("DRONE_REPO_OWNER"),
+			Link:          getenv("DRONE_REPO_LINK"),
+			DefaultBranch: getenv("DRONE_REPO_BRANCH"),
+		},
+		Branch: getenv("DRONE_COMMIT_BRANCH"),
+		Tag:    getenv("DRONE_TAG"),
+	}
+}
+
+func readVela(getenv func(string) string) Context {
+	status := ""
+	switch getenv("VELA_BUILD_STATUS") {
+	case "success", "running": // When none of the previous steps have failed, VELA_BUILD_STATUS has the value running within a step
+		status = statusSuccess
+	case "failure", "error":
+		status = statusFailure
+	}
+
+	return Context{
+		Build: Build{
+			Number:   getenv("VELA_BUILD_NUMBER"),
+			Status:   status,
+			Link:     getenv("VELA_BUILD_LINK"),
+			Event:    getenv("VELA_BUILD_EVENT"),
+			Started:  getenv("VELA_BUILD_STARTED"),
+			Finished: getenv("VELA_BUILD_FINISHED"),
+		},
+		Commit: Commit{
+			Sha:         getenv("VELA_BUILD_COMMIT"),
+			Message:     getenv("VELA_BUILD_MESSAGE"),
+			Author:      getenv("VELA_BUILD_AUTHOR"),
+			AuthorEmail: getenv("VELA_BUILD_AUTHOR_EMAIL"),
+			Link:        getenv("VELA_BUILD_SOURCE"),
+		},
+		Repo: Repo{
+			Name:          getenv("VELA_REPO_FULL_NAME"),
+			Owner:         getenv("VELA_REPO_ORG"),
+			Link:          getenv("VELA_REPO_LINK"),
+			DefaultBranch: getenv("VELA_REPO_BRANCH"),
+		},
+		Branch: getenv("VELA_BUILD_BRANCH"),
+		Tag:    getenv("VELA_BUILD_TAG"),
+	}
+}
+
+// CircleCI doesn't expose the status, the commit message or the default
+// branch to the job
+func readCircleCi(getenv func(string) string) Context {
+	context := Context{
+		Build: Build{
+			Number: getenv("CIRCLE_BUILD_NUM"),
+			Link:   getenv("CIRCLE_BUILD_URL"),
+		},
+		Commit: Commit{
+			Sha:    getenv("CIRCLE_SHA1"),
+			Author: getenv("CIRCLE_USERNAME"),
+			Link:   getenv("CIRCLE_COMPARE_URL"),
+		},
+		Repo: Repo{
+			Name:  getenv("CIRCLE_PROJECT_USERNAME") + "/" + getenv("CIRCLE_PROJECT_REPONAME"),
+			Owner: getenv("CIRCLE_PROJECT_USERNAME"),
+			Link:  getenv("CIRCLE_REPOSITORY_URL"),
+		},
+		Branch: getenv("CIRCLE_BRANCH"),
+		Tag:    getenv("CIRCLE_TAG"),
+	}
+
+	if context.Tag != "" {
+		context.Build.Event = "tag"
+	} else if getenv("CIRCLE_PULL_REQUEST") != "" {
+		context.Build.Event = "pull_request"
+	} else {
+		context.Build.Event = "push"
+	}
+
+	return context
+}
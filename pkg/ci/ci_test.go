@@ -0,0 +1,139 @@
+//go:build test
+// +build test
+
+package ci
+
+import (
+	"testing"
+
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentForDrone(test *testing.T) {
+	variables := map[string]string{
+		"DRONE":                     "true",
+		"DRONE_BUILD_NUMBER":        "42",
+		"DRONE_BUILD_STATUS":        "killed",
+		"DRONE_BUILD_LINK":          "https://drone.example.com/octocat/hello-world/42",
+		"DRONE_BUILD_EVENT":         "push",
+		"DRONE_BUILD_STARTED":       "1700000000",
+		"DRONE_BUILD_FINISHED":      "1700000090",
+		"DRONE_COMMIT_SHA":          "bcdd4bf0245c82c060407b3b24b9b87301d15ac1",
+		"DRONE_COMMIT_MESSAGE":      "Updated readme",
+		"DRONE_COMMIT_AUTHOR":       "octocat",
+		"DRONE_COMMIT_AUTHOR_EMAIL": "octocat@github.com",
+		"DRONE_COMMIT_LINK":         "https://github.com/octocat/hello-world/commit/bcdd4bf",
+		"DRONE_REPO":                "octocat/hello-world",
+		"DRONE_REPO_OWNER":          "octocat",
+		"DRONE_REPO_LINK":           "https://github.com/octocat/hello-world",
+		"DRONE_REPO_BRANCH":         "main",
+		"DRONE_COMMIT_BRANCH":       "feature",
+		"DRONE_TAG":                 "",
+	}
+	for name, value := range variables {
+		helper.SetEnvironmentVariable(test, name, value)
+	}
+
+	assert.Equal(test, Context{
+		System: "drone",
+		Build: Build{
+			Number:   "42",
+			Status:   "failure",
+			Link:     "https://drone.example.com/octocat/hello-world/42",
+			Event:    "push",
+			Started:  "1700000000",
+			Finished: "1700000090",
+		},
+		Commit: Commit{
+			Sha:         "bcdd4bf0245c82c060407b3b24b9b87301d15ac1",
+			Message:     "Updated readme",
+			Author:      "octocat",
+			AuthorEmail: "octocat@github.com",
+			Link:        "https://github.com/octocat/hello-world/commit/bcdd4bf",
+		},
+		Repo: Repo{
+			Name:          "octocat/hello-world",
+			Owner:         "octocat",
+			Link:          "https://github.com/octocat/hello-world",
+			DefaultBranch: "main",
+		},
+		Branch: "feature",
+	}, Current())
+}
+
+func TestCurrentForVela(test *testing.T) {
+	variables := map[string]string{
+		"VELA":                    "true",
+		"VELA_BUILD_NUMBER":       "7",
+		"VELA_BUILD_STATUS":       "running",
+		"VELA_BUILD_LINK":         "https://vela.example.com/octocat/hello-world/7",
+		"VELA_BUILD_EVENT":        "tag",
+		"VELA_BUILD_COMMIT":       "bcdd4bf0245c82c060407b3b24b9b87301d15ac1",
+		"VELA_BUILD_MESSAGE":      "Release 1.0.0",
+		"VELA_BUILD_AUTHOR":       "octocat",
+		"VELA_BUILD_AUTHOR_EMAIL": "octocat@github.com",
+		"VELA_BUILD_SOURCE":       "https://github.com/octocat/hello-world/commit/bcdd4bf",
+		"VELA_REPO_FULL_NAME":     "octocat/hello-world",
+		"VELA_REPO_ORG":           "octocat",
+		"VELA_REPO_LINK":          "https://github.com/octocat/hello-world",
+		"VELA_REPO_BRANCH":        "main",
+		"VELA_BUILD_BRANCH":       "main",
+		"VELA_BUILD_TAG":          "v1.0.0",
+	}
+	for name, value := range variables {
+		helper.SetEnvironmentVariable(test, name, value)
+	}
+
+	context := Current()
+	assert.Equal(test, "vela", context.System)
+	assert.Equal(test, Build{
+		Number: "7",
+		Status: "success",
+		Link:   "https://vela.example.com/octocat/hello-world/7",
+		Event:  "tag",
+	}, context.Build)
+	assert.Equal(test, "octocat", context.Commit.Author)
+	assert.Equal(test, "https://github.com/octocat/hello-world/commit/bcdd4bf", context.Commit.Link)
+	assert.Equal(test, "octocat/hello-world", context.Repo.Name)
+	assert.Equal(test, "main", context.Repo.DefaultBranch)
+	assert.Equal(test, "v1.0.0", context.Tag)
+}
+
+func TestCurrentForCircleCi(test *testing.T) {
+	cases := []struct{ tag, pullRequest, event string }{
+		{"", "", "push"},
+		{"", "https://github.com/octocat/hello-world/pull/1", "pull_request"},
+		{"v1.0.0", "", "tag"},
+	}
+
+	for _, data := range cases {
+		helper.SetEnvironmentVariable(test, "CIRCLECI", "true")
+		helper.SetEnvironmentVariable(test, "CIRCLE_BUILD_NUM", "99")
+		helper.SetEnvironmentVariable(test, "CIRCLE_SHA1", "bcdd4bf0245c82c060407b3b24b9b87301d15ac1")
+		helper.SetEnvironmentVariable(test, "CIRCLE_USERNAME", "octocat")
+		helper.SetEnvironmentVariable(test, "CIRCLE_PROJECT_USERNAME", "octocat")
+		helper.SetEnvironmentVariable(test, "CIRCLE_PROJECT_REPONAME", "hello-world")
+		helper.SetEnvironmentVariable(test, "CIRCLE_BRANCH", "main")
+		helper.SetEnvironmentVariable(test, "CIRCLE_TAG", data.tag)
+		helper.SetEnvironmentVariable(test, "CIRCLE_PULL_REQUEST", data.pullRequest)
+
+		context := Current()
+		assert.Equal(test, "circleci", context.System)
+		assert.Equal(test, "99", context.Build.Number)
+		assert.Equal(test, "", context.Build.Status)
+		assert.Equal(test, data.event, context.Build.Event)
+		assert.Equal(test, "octocat", context.Commit.Author)
+		assert.Equal(test, "octocat/hello-world", context.Repo.Name)
+		assert.Equal(test, "main", context.Branch)
+	}
+}
+
+func TestCurrentForOtherCi(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+	for _, variable := range []string{"DRONE", "VELA", "CIRCLECI"} {
+		helper.SetEnvironmentVariable(test, variable, "")
+	}
+
+	assert.Equal(test, Context{}, Current())
+}
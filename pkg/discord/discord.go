@@ -0,0 +1,188 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+)
+
+const provider string = "discord"
+
+// Limits imposed by Discord on the parts of a message
+const (
+	maxContentLength     int = 2000
+	maxTitleLength       int = 256
+	maxDescriptionLength int = 4096
+	maxFieldNameLength   int = 256
+	maxFieldValueLength  int = 1024
+	maxFields            int = 25
+)
+
+func init() {
+	notifier.Register(provider, newDiscordNotifier)
+}
+
+// Posts messages as embeds to a Discord webhook
+type discordNotifier struct {
+	sender    *notifier.HttpSender
+	webhook   string
+	username  string
+	avatarUrl string
+	content   string
+}
+
+// Message posted to the webhook
+type Payload struct {
+	Content   string  `json:"content,omitempty"`
+	Username  string  `json:"username,omitempty"`
+	AvatarUrl string  `json:"avatar_url,omitempty"`
+	Embeds    []Embed `json:"embeds"`
+}
+
+type Embed struct {
+	Title       string  `json:"title,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Color       int     `json:"color"`
+	Fields      []Field `json:"fields,omitempty"`
+}
+
+type Field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Response of the webhook when called with wait=true, and of a failed call
+type response struct {
+	Id         string  `json:"id,omitempty"`
+	ChannelId  string  `json:"channel_id,omitempty"`
+	Message    string  `json:"message,omitempty"`
+	RetryAfter float64 `json:"retry_after,omitempty"` // In seconds
+}
+
+func newDiscordNotifier(config notifier.Config) (notifier.Notifier, error) {
+	if config.Webhook == "" {
+		return nil, errors.New("Webhook is required to post to Discord")
+	}
+
+	webhook, err := url.Parse(config.Webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	// Makes Discord respond with the posted message
+	query := webhook.Query()
+	query.Set("wait", "true")
+	webhook.RawQuery = query.Encode()
+
+	sender := notifier.NewHttpSender("Discord", config)
+	sender.ReadError = readError
+
+	return &discordNotifier{
+		sender:    sender,
+		webhook:   webhook.String(),
+		username:  config.Setting("username", ""),
+		avatarUrl: config.Setting("avatar_url", ""),
+		content:   config.Setting("content", ""),
+	}, nil
+}
+
+func (discord *discordNotifier) Notify(ctx context.Context, message notifier.Message) (result notifier.Result, err error) {
+	payload, err := BuildPayload(ctx, message)
+	if err != nil {
+		return
+	}
+	payload.Username = discord.username
+	payload.AvatarUrl = discord.avatarUrl
+
+	// Mentions notify only when in the content, not within an embed
+	payload.Content, err = notifier.ParseTemplate(ctx, discord.content)
+	if err != nil {
+		return
+	}
+	payload.Content = notifier.Truncate(payload.Content, maxContentLength)
+
+	responseBody, err := discord.sender.PostJson(ctx, discord.webhook, payload)
+	if err != nil {
+		return
+	}
+
+	discordResponse := response{}
+	if json.Unmarshal(responseBody, &discordResponse) == nil {
+		result.Channel = discordResponse.ChannelId
+		result.Id = discordResponse.Id
+	}
+
+	return
+}
+
+// Renders the message as an embed, truncating the parts that exceed
+// Discord's limits. Links are appended to the description, as webhooks can't
+// post buttons
+func BuildPayload(ctx context.Context, message notifier.Message) (payload Payload, err error) {
+	message, err = message.Render(ctx)
+	if err != nil {
+		return
+	}
+
+	description := message.Text
+	for _, link := range message.Links {
+		if description != "" {
+			description += "\n"
+		}
+		description += "[" + link.Text + "](" + link.Url + ")"
+	}
+
+	embed := Embed{
+		Title:       notifier.Truncate(message.Title, maxTitleLength),
+		Description: notifier.Truncate(description, maxDescriptionLength),
+		Color:       colorToInt(message.Color),
+	}
+
+	for index, field := range message.Fields {
+		if index == maxFields {
+			break
+		}
+
+		embed.Fields = append(embed.Fields, Field{
+			Name:   notifier.Truncate(field.Title, maxFieldNameLength),
+			Value:  notifier.Truncate(field.Value, maxFieldValueLength),
+			Inline: field.Short,
+		})
+	}
+
+	payload.Embeds = []Embed{embed}
+	return
+}
+
+// Converts a hex color like #33ad7f into the integer Discord expects.
+// Invalid colors are treated as black
+func colorToInt(color string) int {
+	value, err := strconv.ParseInt(strings.TrimPrefix(notifier.HexColor(color), "#"), 16, 32)
+	if err != nil {
+		return 0
+	}
+
+	return int(value)
+}
+
+// Reads the reason and the delay requested on rate limits from Discord's
+// JSON error body, which takes precedence over the Retry-After header
+func readError(deliveryError *notifier.DeliveryError, body []byte) {
+	discordResponse := response{}
+	if json.Unmarshal(body, &discordResponse) == nil {
+		if discordResponse.Message != "" {
+			deliveryError.Reason = discordResponse.Message
+		}
+
+		if discordResponse.RetryAfter > 0 {
+			deliveryError.RetryAfter = time.Duration(discordResponse.RetryAfter * float64(time.Second))
+		}
+	}
+}
@@ -0,0 +1,139 @@
+//go:build test
+// +build test
+
+package discord
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "success")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_LINK", "https://someurl")
+
+	// Test HTTP server
+	var capturedRequest []byte
+	var capturedQuery string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = io.ReadAll(request.Body)
+		capturedQuery = request.URL.RawQuery
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"id":"1234","channel_id":"5678"}`)
+	}))
+	defer testServer.Close()
+
+	backend, err := notifier.New("discord", notifier.Config{
+		Webhook: testServer.URL + "/api/webhooks/1/token",
+		Settings: map[string]string{
+			"username":   "CI",
+			"avatar_url": "https://avatar",
+			"content":    "<@&123> build {{.DroneBuildStatus}}",
+		},
+	})
+	assert.Nil(test, err)
+
+	result, err := backend.Notify(context.Background(), notifier.Message{
+		Title:  "Build notification",
+		Text:   "Build {{.DroneBuildStatus}}",
+		Fields: []notifier.Field{{Title: "Branch", Value: "master", Short: true}},
+		Links:  []notifier.Link{{Text: "Open build", Url: "{{.DroneBuildLink}}"}},
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, notifier.Result{Channel: "5678", Id: "1234"}, result)
+	assert.Equal(test, "wait=true", capturedQuery)
+
+	expected := `{
+		"content": "<@&123> build success",
+		"username": "CI",
+		"avatar_url": "https://avatar",
+		"embeds": [{
+			"title": "Build notification",
+			"description": "Build success\n[Open build](https://someurl)",
+			"color": 3386751,
+			"fields": [{"name": "Branch", "value": "master", "inline": true}]
+		}]
+	}`
+	assert.JSONEq(test, expected, string(capturedRequest))
+}
+
+func TestNotifyRateLimited(test *testing.T) {
+	requests := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests++
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintln(writer, `{"message":"You are being rate limited.","retry_after":0.001,"global":false}`)
+	}))
+	defer testServer.Close()
+
+	backend, _ := notifier.New("discord", notifier.Config{
+		Webhook: testServer.URL,
+		Retry:   notifier.RetryPolicy{MaxRetries: 2},
+	})
+	_, err := backend.Notify(context.Background(), notifier.Message{Text: "Build failed!"})
+
+	var deliveryError *notifier.DeliveryError
+	assert.ErrorAs(test, err, &deliveryError)
+	assert.Equal(test, 3, requests)
+	assert.True(test, deliveryError.Retryable)
+	assert.Equal(test, time.Millisecond, deliveryError.RetryAfter)
+	assert.Equal(test, "HTTP request to Discord failed: You are being rate limited.", err.Error())
+}
+
+func TestNewWithoutWebhook(test *testing.T) {
+	_, err := notifier.New("discord", notifier.Config{})
+
+	assert.Equal(test, "Webhook is required to post to Discord", err.Error())
+}
+
+func TestBuildPayloadLimits(test *testing.T) {
+	message := notifier.Message{
+		Title: strings.Repeat("t", 300),
+		Text:  strings.Repeat("é", 5000),
+		Color: "danger",
+	}
+	for index := 0; index < 30; index++ {
+		message.Fields = append(message.Fields, notifier.Field{Title: "Name", Value: strings.Repeat("v", 2000)})
+	}
+
+	payload, err := BuildPayload(context.Background(), message)
+
+	assert.Nil(test, err)
+	embed := payload.Embeds[0]
+	assert.Equal(test, 256, len([]rune(embed.Title)))
+	assert.Equal(test, 4096, len([]rune(embed.Description)))
+	assert.True(test, strings.HasSuffix(embed.Description, "…"))
+	assert.Equal(test, 25, len(embed.Fields))
+	assert.Equal(test, 1024, len([]rune(embed.Fields[0].Value)))
+	assert.Equal(test, 0xa1040c, embed.Color)
+}
+
+func TestColorToInt(test *testing.T) {
+	cases := []struct {
+		color    string
+		expected int
+	}{
+		{"#33ad7f", 0x33ad7f},
+		{"cfd3d7", 0xcfd3d7},
+		{"good", 0x33ad7f},
+		{"red", 0xa1040c},
+		{"blue", 0},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, colorToInt(data.color))
+	}
+}
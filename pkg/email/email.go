@@ -0,0 +1,386 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	log "github.com/sirupsen/logrus"
+)
+
+const provider string = "email"
+
+const defaultPort string = "587"
+const defaultTimeout time.Duration = 30 * time.Second
+
+// Creates the TLS config used for STARTTLS. Replaced in tests to trust the
+// certificate of the test server
+var newTlsConfig = func(host string) *tls.Config {
+	return &tls.Config{ServerName: host}
+}
+
+func init() {
+	notifier.Register(provider, newEmailNotifier)
+}
+
+// Sends messages as multipart emails through an SMTP server
+type emailNotifier struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+	to       []string
+	subject  string
+	startTls bool
+	timeout  time.Duration
+	retry    notifier.RetryPolicy
+}
+
+func newEmailNotifier(config notifier.Config) (notifier.Notifier, error) {
+	email := &emailNotifier{
+		host:     setting(config, "smtp_host", "SMTP_HOST", ""),
+		port:     setting(config, "smtp_port", "SMTP_PORT", defaultPort),
+		username: config.Setting("smtp_username", ""),
+		password: config.Setting("smtp_password", ""),
+		from:     setting(config, "email_from", "SMTP_FROM", ""),
+		subject:  config.Setting("subject", ""),
+		timeout:  defaultTimeout,
+		retry:    config.Retry,
+	}
+
+	for _, recipient := range strings.Split(config.Setting("email_to", config.Channel), ",") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			email.to = append(email.to, recipient)
+		}
+	}
+
+	if email.host == "" || email.from == "" || len(email.to) == 0 {
+		return nil, errors.New("SMTP host, sender and recipients are required to send an email")
+	}
+
+	// Without credentials of its own, a request sends through the server's
+	// account. Those are only sent to the server's host, so that an API
+	// request can't send them to a host of its choosing
+	if email.username == "" && email.password == "" && os.Getenv("SMTP_USERNAME") != "" && email.host == os.Getenv("SMTP_HOST") {
+		err := checkServerAccountUse(config, email.to)
+		if err != nil {
+			return nil, err
+		}
+
+		email.username = os.Getenv("SMTP_USERNAME")
+		email.password = os.Getenv("SMTP_PASSWORD")
+	}
+
+	startTls, err := strconv.ParseBool(setting(config, "smtp_starttls", "SMTP_STARTTLS", "true"))
+	if err != nil {
+		return nil, err
+	}
+	email.startTls = startTls
+
+	if config.HttpClient != nil && config.HttpClient.Timeout > 0 {
+		email.timeout = config.HttpClient.Timeout
+	}
+
+	return email, nil
+}
+
+// Keeps the server's SMTP account from relaying arbitrary emails. The sender
+// is the server's SMTP_FROM and the recipients have to match the addresses
+// or globs, like *@example.com, in SMTP_ALLOWED_RECIPIENTS
+func checkServerAccountUse(config notifier.Config, recipients []string) error {
+	if from := config.Setting("email_from", ""); from != "" && from != os.Getenv("SMTP_FROM") {
+		return errors.New("The sender can't be changed when sending through the server's SMTP account. Specify smtp_username and smtp_password to send as " + from)
+	}
+
+	allowed := strings.Split(strings.ToLower(os.Getenv("SMTP_ALLOWED_RECIPIENTS")), ",")
+	for _, recipient := range recipients {
+		if !matchesAny(allowed, strings.ToLower(recipient)) {
+			return errors.New("Recipient " + recipient + " isn't allowed by the server's SMTP account. Specify smtp_username and smtp_password to send to it")
+		}
+	}
+
+	return nil
+}
+
+// Checks if the address matches any of the addresses or globs
+func matchesAny(patterns []string, address string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if matched, _ := path.Match(pattern, address); pattern != "" && (matched || pattern == address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reads a setting, falling back to the server config in the environment
+func setting(config notifier.Config, name string, envVariable string, defaultValue string) string {
+	if value := os.Getenv(envVariable); value != "" {
+		defaultValue = value
+	}
+
+	return config.Setting(name, defaultValue)
+}
+
+func (email *emailNotifier) Notify(ctx context.Context, message notifier.Message) (result notifier.Result, err error) {
+	messageId := newMessageId()
+	data, err := email.buildEmail(ctx, message, messageId)
+	if err != nil {
+		return
+	}
+
+	err = notifier.Retry(ctx, email.retry, log.StandardLogger(), func() error {
+		return email.send(ctx, data)
+	})
+	if err != nil {
+		return
+	}
+
+	result.Id = messageId
+	return
+}
+
+// Delivers the email through a single SMTP session
+func (email *emailNotifier) send(ctx context.Context, data []byte) error {
+	dialer := net.Dialer{Timeout: email.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(email.host, email.port))
+	if err != nil {
+		return email.newDeliveryError(err)
+	}
+	conn.SetDeadline(time.Now().Add(email.timeout))
+
+	client, err := smtp.NewClient(conn, email.host)
+	if err != nil {
+		conn.Close()
+		return email.newDeliveryError(err)
+	}
+	defer client.Close()
+
+	if email.startTls {
+		if supported, _ := client.Extension("STARTTLS"); !supported {
+			return errors.New("SMTP server " + email.host + " doesn't support STARTTLS")
+		}
+
+		err = client.StartTLS(newTlsConfig(email.host))
+		if err != nil {
+			return email.newDeliveryError(err)
+		}
+	}
+
+	if email.username != "" {
+		err = client.Auth(smtp.PlainAuth("", email.username, email.password, email.host))
+		if err != nil {
+			return email.newDeliveryError(err)
+		}
+	}
+
+	err = client.Mail(email.from)
+	if err != nil {
+		return email.newDeliveryError(err)
+	}
+
+	for _, recipient := range email.to {
+		err = client.Rcpt(recipient)
+		if err != nil {
+			return email.newDeliveryError(err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return email.newDeliveryError(err)
+	}
+
+	_, err = writer.Write(data)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return email.newDeliveryError(err)
+	}
+
+	return client.Quit()
+}
+
+// Converts an SMTP error into a DeliveryError. Replies with a 4xx code and
+// network errors are transient, while 5xx replies are permanent
+func (email *emailNotifier) newDeliveryError(err error) error {
+	deliveryError := &notifier.DeliveryError{Provider: "Email", Host: email.host, Protocol: "SMTP"}
+
+	var protocolError *textproto.Error
+	if errors.As(err, &protocolError) {
+		deliveryError.StatusCode = protocolError.Code
+		deliveryError.Reason = protocolError.Msg
+		deliveryError.Retryable = protocolError.Code >= 400 && protocolError.Code < 500
+		return deliveryError
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) {
+		deliveryError.Err = err
+		deliveryError.Retryable = true
+		return deliveryError
+	}
+
+	deliveryError.Err = err
+	return deliveryError
+}
+
+// Renders the message as a multipart email, with a plain text part and an
+// HTML part that shows the color as a bar beside the message
+func (email *emailNotifier) buildEmail(ctx context.Context, message notifier.Message, messageId string) ([]byte, error) {
+	message, err := message.Render(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := notifier.ParseTemplate(ctx, email.subject)
+	if err != nil {
+		return nil, err
+	}
+	if subject == "" {
+		subject = defaultSubject(message)
+	}
+
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+
+	headers := []string{
+		"From: " + email.from,
+		"To: " + strings.Join(email.to, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: " + messageId,
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/alternative; boundary="` + writer.Boundary() + `"`,
+	}
+	buffer.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	err = writePart(writer, "text/plain; charset=UTF-8", BuildText(message))
+	if err != nil {
+		return nil, err
+	}
+
+	err = writePart(writer, "text/html; charset=UTF-8", BuildHtml(message))
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	return buffer.Bytes(), err
+}
+
+// Writes a quoted-printable part
+func writePart(writer *multipart.Writer, contentType string, content string) error {
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := quotedprintable.NewWriter(part)
+	_, err = encoder.Write([]byte(content))
+	if err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// Uses the title, or the first line of the text, as the subject
+func defaultSubject(message notifier.Message) string {
+	if message.Title != "" {
+		return message.Title
+	}
+
+	subject, _, _ := strings.Cut(message.Text, "\n")
+	return subject
+}
+
+// Renders the rendered message as plain text
+func BuildText(message notifier.Message) string {
+	lines := []string{}
+	if message.Title != "" {
+		lines = append(lines, message.Title, "")
+	}
+	if message.Text != "" {
+		lines = append(lines, message.Text)
+	}
+
+	if len(message.Fields) > 0 {
+		lines = append(lines, "")
+		for _, field := range message.Fields {
+			lines = append(lines, field.Title+": "+field.Value)
+		}
+	}
+
+	if len(message.Links) > 0 {
+		lines = append(lines, "")
+		for _, link := range message.Links {
+			lines = append(lines, link.Text+": "+link.Url)
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Renders the rendered message as HTML, with the color as a bar on the left
+func BuildHtml(message notifier.Message) string {
+	var builder strings.Builder
+	builder.WriteString(`<html><body><div style="border-left: 6px solid ` + html.EscapeString(notifier.HexColor(message.Color)) +
+		`; padding: 4px 12px; font-family: sans-serif;">`)
+
+	if message.Title != "" {
+		builder.WriteString("<h3>" + html.EscapeString(message.Title) + "</h3>")
+	}
+	if message.Text != "" {
+		builder.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(message.Text), "\n", "<br>") + "</p>")
+	}
+
+	if len(message.Fields) > 0 {
+		builder.WriteString("<table>")
+		for _, field := range message.Fields {
+			builder.WriteString("<tr><th align=\"left\">" + html.EscapeString(field.Title) + "</th><td>" + html.EscapeString(field.Value) + "</td></tr>")
+		}
+		builder.WriteString("</table>")
+	}
+
+	if len(message.Links) > 0 {
+		links := []string{}
+		for _, link := range message.Links {
+			links = append(links, `<a href="`+html.EscapeString(link.Url)+`">`+html.EscapeString(link.Text)+"</a>")
+		}
+		builder.WriteString("<p>" + strings.Join(links, " | ") + "</p>")
+	}
+
+	builder.WriteString("</div></body></html>")
+	return builder.String()
+}
+
+// Generates a unique Message-ID header value
+func newMessageId() string {
+	random := make([]byte, 12)
+	rand.Read(random)
+
+	return "<" + hex.EncodeToString(random) + "@simple-slack>"
+}
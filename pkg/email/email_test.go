@@ -0,0 +1,463 @@
+//go:build test
+// +build test
+
+package email
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Session recorded by the SMTP stand-in
+type smtpSession struct {
+	StartTls   bool
+	Auth       string
+	From       string
+	Recipients []string
+	Data       string
+}
+
+// Sessions recorded by the SMTP stand-in
+type smtpRecorder struct {
+	mutex    sync.Mutex
+	sessions []smtpSession
+}
+
+func (recorder *smtpRecorder) add(session smtpSession) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.sessions = append(recorder.sessions, session)
+}
+
+func (recorder *smtpRecorder) Sessions() []smtpSession {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return append([]smtpSession{}, recorder.sessions...)
+}
+
+// Starts a stand-in for an SMTP server that records the sessions. Replies to
+// RCPT commands with the reply code, when specified
+func startSmtpServer(test *testing.T, supportStartTls bool, rcptReply string) (string, *smtpRecorder) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(test, err)
+	test.Cleanup(func() { listener.Close() })
+
+	tlsConfig := newTestTlsConfig(test)
+	recorder := &smtpRecorder{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			recorder.add(serveSmtp(conn, tlsConfig, supportStartTls, rcptReply))
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	helper.SetEnvironmentVariable(test, "SMTP_HOST", host)
+	helper.SetEnvironmentVariable(test, "SMTP_PORT", port)
+
+	return host, recorder
+}
+
+func serveSmtp(conn net.Conn, tlsConfig *tls.Config, supportStartTls bool, rcptReply string) (session smtpSession) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(command) {
+		case "EHLO":
+			extensions := []string{"250-localhost", "250-AUTH PLAIN"}
+			if supportStartTls && !session.StartTls {
+				extensions = append(extensions, "250-STARTTLS")
+			}
+			text.PrintfLine(strings.Join(extensions, "\r\n") + "\r\n250 8BITMIME")
+		case "STARTTLS":
+			text.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			session.StartTls = true
+		case "AUTH":
+			session.Auth = argument
+			text.PrintfLine("235 Authenticated")
+		case "MAIL":
+			session.From = argument
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			if rcptReply != "" {
+				text.PrintfLine(rcptReply)
+				continue
+			}
+			session.Recipients = append(session.Recipients, argument)
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Send message")
+			data, _ := text.ReadDotBytes()
+			session.Data = string(data)
+			text.PrintfLine("250 Queued")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// Creates a self signed certificate for the stand-in and trusts it in the
+// notifier
+func newTestTlsConfig(test *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(test, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.Nil(test, err)
+
+	parsed, _ := x509.ParseCertificate(certificate)
+	roots := x509.NewCertPool()
+	roots.AddCert(parsed)
+
+	originalTlsConfig := newTlsConfig
+	newTlsConfig = func(host string) *tls.Config {
+		return &tls.Config{ServerName: host, RootCAs: roots}
+	}
+	test.Cleanup(func() { newTlsConfig = originalTlsConfig })
+
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{certificate}, PrivateKey: key}}}
+}
+
+// Reads the text and HTML parts of the email
+func readParts(test *testing.T, data string) (*mail.Message, map[string]string) {
+	email, err := mail.ReadMessage(strings.NewReader(data))
+	assert.Nil(test, err)
+
+	_, params, err := mime.ParseMediaType(email.Header.Get("Content-Type"))
+	assert.Nil(test, err)
+
+	parts := make(map[string]string)
+	reader := multipart.NewReader(email.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+
+		content := new(strings.Builder)
+		buffer := make([]byte, 4096)
+		for {
+			count, err := part.Read(buffer)
+			content.Write(buffer[:count])
+			if err != nil {
+				break
+			}
+		}
+		parts[part.Header.Get("Content-Type")] = content.String()
+	}
+
+	return email, parts
+}
+
+func TestNotify(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+	host, recorder := startSmtpServer(test, true, "")
+
+	backend, err := notifier.New("email", notifier.Config{
+		Settings: map[string]string{
+			"smtp_username": "builds",
+			"smtp_password": "secret",
+			"email_from":    "ci@example.com",
+			"email_to":      "dev@example.com, ops@example.com",
+			"subject":       "Build of {{.DroneRepo}}",
+		},
+	})
+	assert.Nil(test, err)
+
+	result, err := backend.Notify(context.Background(), notifier.Message{
+		Title:  "Release <pipeline>",
+		Text:   "Build failed\non main",
+		Color:  "danger",
+		Fields: []notifier.Field{{Title: "Commit", Value: "abc123"}},
+		Links:  []notifier.Link{{Text: "Open build", Url: "https://someurl"}},
+	})
+	assert.Nil(test, err)
+	assert.Equal(test, "127.0.0.1", host)
+
+	assert.Eventually(test, func() bool { return len(recorder.Sessions()) == 1 }, time.Second, time.Millisecond)
+	session := recorder.Sessions()[0]
+	assert.True(test, session.StartTls)
+	assert.Equal(test, "PLAIN AGJ1aWxkcwBzZWNyZXQ=", session.Auth)
+	assert.Equal(test, "FROM:<ci@example.com>", session.From[:21])
+	assert.Equal(test, []string{"TO:<dev@example.com>", "TO:<ops@example.com>"}, session.Recipients)
+
+	email, parts := readParts(test, session.Data)
+	assert.Equal(test, "ci@example.com", email.Header.Get("From"))
+	assert.Equal(test, "dev@example.com, ops@example.com", email.Header.Get("To"))
+	assert.Equal(test, "Build of octocat/hello-world", email.Header.Get("Subject"))
+	assert.Equal(test, result.Id, email.Header.Get("Message-ID"))
+
+	assert.Equal(test, "Release <pipeline>\n\nBuild failed\non main\n\nCommit: abc123\n\nOpen build: https://someurl\n",
+		parts["text/plain; charset=UTF-8"])
+	assert.Equal(test, `<html><body><div style="border-left: 6px solid #a1040c; padding: 4px 12px; font-family: sans-serif;">`+
+		`<h3>Release &lt;pipeline&gt;</h3><p>Build failed<br>on main</p>`+
+		`<table><tr><th align="left">Commit</th><td>abc123</td></tr></table>`+
+		`<p><a href="https://someurl">Open build</a></p></div></body></html>`,
+		parts["text/html; charset=UTF-8"])
+}
+
+func TestNotifyWithServerCredentials(test *testing.T) {
+	host, recorder := startSmtpServer(test, true, "")
+	port := os.Getenv("SMTP_PORT")
+	helper.SetEnvironmentVariable(test, "SMTP_USERNAME", "builds")
+	helper.SetEnvironmentVariable(test, "SMTP_PASSWORD", "secret")
+	helper.SetEnvironmentVariable(test, "SMTP_FROM", "ci@example.com")
+	helper.SetEnvironmentVariable(test, "SMTP_ALLOWED_RECIPIENTS", "*@example.com")
+
+	cases := []struct {
+		settings map[string]string
+		expected string
+	}{
+		{
+			map[string]string{},
+			"PLAIN AGJ1aWxkcwBzZWNyZXQ=",
+		},
+		{
+			map[string]string{"smtp_host": host},
+			"PLAIN AGJ1aWxkcwBzZWNyZXQ=",
+		},
+		{
+			map[string]string{"smtp_host": "localhost", "smtp_port": port, "smtp_starttls": "false"},
+			"",
+		},
+		{
+			map[string]string{"smtp_host": "localhost", "smtp_port": port, "smtp_starttls": "false", "smtp_username": "builds", "smtp_password": "secret"},
+			"PLAIN AGJ1aWxkcwBzZWNyZXQ=",
+		},
+	}
+
+	for index, data := range cases {
+		data.settings["email_from"] = "ci@example.com"
+		backend, err := notifier.New("email", notifier.Config{Channel: "dev@example.com", Settings: data.settings})
+		assert.Nil(test, err)
+
+		_, err = backend.Notify(context.Background(), notifier.Message{Text: "Build succeeded"})
+		assert.Nil(test, err)
+
+		assert.Eventually(test, func() bool { return len(recorder.Sessions()) == index+1 }, time.Second, time.Millisecond)
+		assert.Equal(test, data.expected, recorder.Sessions()[index].Auth)
+	}
+}
+
+func TestNotifyThroughServerAccount(test *testing.T) {
+	_, recorder := startSmtpServer(test, true, "")
+	helper.SetEnvironmentVariable(test, "SMTP_USERNAME", "builds")
+	helper.SetEnvironmentVariable(test, "SMTP_PASSWORD", "secret")
+	helper.SetEnvironmentVariable(test, "SMTP_FROM", "ci@example.com")
+	helper.SetEnvironmentVariable(test, "SMTP_ALLOWED_RECIPIENTS", "ops@example.com, *@dev.example.com")
+
+	cases := []struct {
+		settings map[string]string
+		expected string
+	}{
+		{
+			map[string]string{"email_from": "ceo@example.com", "email_to": "ops@example.com"},
+			"The sender can't be changed when sending through the server's SMTP account. Specify smtp_username and smtp_password to send as ceo@example.com",
+		},
+		{
+			map[string]string{"email_to": "ops@example.com, victim@example.org"},
+			"Recipient victim@example.org isn't allowed by the server's SMTP account. Specify smtp_username and smtp_password to send to it",
+		},
+		{
+			map[string]string{"email_from": "ci@example.com", "email_to": "OPS@example.com, alice@dev.example.com"},
+			"",
+		},
+		{
+			map[string]string{"email_from": "ceo@example.com", "email_to": "victim@example.org", "smtp_username": "other", "smtp_password": "other-secret"},
+			"",
+		},
+	}
+
+	sent := 0
+	for _, data := range cases {
+		backend, err := notifier.New("email", notifier.Config{Settings: data.settings})
+		if data.expected != "" {
+			assert.Equal(test, data.expected, err.Error())
+			continue
+		}
+
+		assert.Nil(test, err)
+		_, err = backend.Notify(context.Background(), notifier.Message{Text: "Build succeeded"})
+		assert.Nil(test, err)
+		sent++
+	}
+
+	// Requests with credentials of their own choose the sender and recipients
+	assert.Eventually(test, func() bool { return len(recorder.Sessions()) == sent }, time.Second, time.Millisecond)
+	sessions := recorder.Sessions()
+	assert.Equal(test, "PLAIN AGJ1aWxkcwBzZWNyZXQ=", sessions[0].Auth)
+	assert.Equal(test, "FROM:<ci@example.com>", sessions[0].From[:21])
+	assert.Equal(test, []string{"TO:<OPS@example.com>", "TO:<alice@dev.example.com>"}, sessions[0].Recipients)
+	assert.Equal(test, "PLAIN AG90aGVyAG90aGVyLXNlY3JldA==", sessions[1].Auth)
+	assert.Equal(test, "FROM:<ceo@example.com>", sessions[1].From[:22])
+	assert.Equal(test, []string{"TO:<victim@example.org>"}, sessions[1].Recipients)
+}
+
+func TestNotifyThroughServerAccountWithoutAllowedRecipients(test *testing.T) {
+	startSmtpServer(test, true, "")
+	helper.SetEnvironmentVariable(test, "SMTP_USERNAME", "builds")
+	helper.SetEnvironmentVariable(test, "SMTP_PASSWORD", "secret")
+	helper.SetEnvironmentVariable(test, "SMTP_FROM", "ci@example.com")
+	helper.SetEnvironmentVariable(test, "SMTP_ALLOWED_RECIPIENTS", "")
+
+	_, err := notifier.New("email", notifier.Config{Channel: "ops@example.com"})
+	assert.Equal(test, "Recipient ops@example.com isn't allowed by the server's SMTP account. Specify smtp_username and smtp_password to send to it", err.Error())
+}
+
+func TestNotifyWithoutStartTls(test *testing.T) {
+	_, recorder := startSmtpServer(test, false, "")
+
+	backend, err := notifier.New("email", notifier.Config{
+		Channel: "dev@example.com",
+		Settings: map[string]string{
+			"email_from":    "ci@example.com",
+			"smtp_starttls": "false",
+		},
+	})
+	assert.Nil(test, err)
+
+	_, err = backend.Notify(context.Background(), notifier.Message{Text: "Build succeeded\nin 2 minutes", Color: "good"})
+	assert.Nil(test, err)
+
+	assert.Eventually(test, func() bool { return len(recorder.Sessions()) == 1 }, time.Second, time.Millisecond)
+	session := recorder.Sessions()[0]
+	assert.False(test, session.StartTls)
+	assert.Equal(test, "", session.Auth)
+	assert.Equal(test, []string{"TO:<dev@example.com>"}, session.Recipients)
+
+	email, parts := readParts(test, session.Data)
+	assert.Equal(test, "Build succeeded", email.Header.Get("Subject"))
+	assert.Contains(test, parts["text/html; charset=UTF-8"], "border-left: 6px solid #33ad7f")
+}
+
+func TestNotifyStartTlsNotSupported(test *testing.T) {
+	startSmtpServer(test, false, "")
+
+	backend, err := notifier.New("email", notifier.Config{
+		Channel:  "dev@example.com",
+		Settings: map[string]string{"email_from": "ci@example.com"},
+	})
+	assert.Nil(test, err)
+
+	_, err = backend.Notify(context.Background(), notifier.Message{Text: "Build succeeded"})
+	assert.Equal(test, "SMTP server 127.0.0.1 doesn't support STARTTLS", err.Error())
+}
+
+func TestNotifyRejected(test *testing.T) {
+	cases := []struct {
+		reply     string
+		retryable bool
+		attempts  int
+		expected  string
+	}{
+		{"550 5.1.1 Mailbox unavailable", false, 1, "SMTP request to 127.0.0.1 failed: 5.1.1 Mailbox unavailable"},
+		{"451 4.3.0 Try again later", true, 2, "SMTP request to 127.0.0.1 failed: 4.3.0 Try again later"},
+	}
+
+	for _, data := range cases {
+		test.Run(data.reply, func(test *testing.T) {
+			_, recorder := startSmtpServer(test, false, data.reply)
+
+			backend, err := notifier.New("email", notifier.Config{
+				Channel: "dev@example.com",
+				Retry:   notifier.RetryPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond},
+				Settings: map[string]string{
+					"email_from":    "ci@example.com",
+					"smtp_starttls": "false",
+				},
+			})
+			assert.Nil(test, err)
+
+			_, err = backend.Notify(context.Background(), notifier.Message{Text: "Build succeeded"})
+			assert.Equal(test, data.expected, err.Error())
+
+			var deliveryError *notifier.DeliveryError
+			assert.True(test, errors.As(err, &deliveryError))
+			assert.Equal(test, data.retryable, deliveryError.Retryable)
+
+			assert.Eventually(test, func() bool {
+				return len(recorder.Sessions()) == data.attempts
+			}, time.Second, time.Millisecond)
+		})
+	}
+}
+
+func TestNewEmailNotifierErrors(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "SMTP_HOST", "")
+
+	cases := []struct {
+		settings map[string]string
+		expected string
+	}{
+		{
+			map[string]string{"email_from": "ci@example.com", "email_to": "dev@example.com"},
+			"SMTP host, sender and recipients are required to send an email",
+		},
+		{
+			map[string]string{"smtp_host": "localhost", "email_to": "dev@example.com"},
+			"SMTP host, sender and recipients are required to send an email",
+		},
+		{
+			map[string]string{"smtp_host": "localhost", "email_from": "ci@example.com", "email_to": " , "},
+			"SMTP host, sender and recipients are required to send an email",
+		},
+		{
+			map[string]string{"smtp_host": "localhost", "email_from": "ci@example.com", "email_to": "dev@example.com", "smtp_starttls": "maybe"},
+			`strconv.ParseBool: parsing "maybe": invalid syntax`,
+		},
+	}
+
+	for _, data := range cases {
+		_, err := notifier.New("email", notifier.Config{Settings: data.settings})
+		assert.Equal(test, data.expected, err.Error())
+	}
+}
@@ -0,0 +1,149 @@
+package generic
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+)
+
+const provider string = "generic"
+
+const defaultContentType string = "application/json"
+
+func init() {
+	notifier.Register(provider, newGenericNotifier)
+}
+
+// Sends messages to any HTTP endpoint, with a body rendered from a template
+type genericNotifier struct {
+	config      notifier.Config
+	url         string
+	method      string
+	contentType string
+	body        string
+	headers     map[string]string
+	auth        string
+}
+
+// Default body, used when no template is specified
+type Payload struct {
+	Title  string           `json:"title,omitempty"`
+	Text   string           `json:"text,omitempty"`
+	Status string           `json:"status,omitempty"`
+	Color  string           `json:"color"`
+	Fields []notifier.Field `json:"fields,omitempty"`
+	Links  []notifier.Link  `json:"links,omitempty"`
+}
+
+func newGenericNotifier(config notifier.Config) (notifier.Notifier, error) {
+	if config.Webhook == "" {
+		return nil, errors.New("Webhook is required to post to a generic endpoint")
+	}
+
+	generic := &genericNotifier{
+		config:      config,
+		url:         config.Webhook,
+		method:      strings.ToUpper(config.Setting("method", "POST")),
+		contentType: config.Setting("content_type", defaultContentType),
+		body:        config.Setting("body", ""),
+		headers:     make(map[string]string),
+	}
+
+	if bodyFile := config.Setting("body_file", ""); bodyFile != "" {
+		body, err := os.ReadFile(bodyFile)
+		if err != nil {
+			return nil, err
+		}
+		generic.body = string(body)
+	}
+
+	if headers := config.Setting("headers", ""); headers != "" {
+		err := notifier.Decode(headers, &generic.headers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The token is the password when a user name is specified and a bearer
+	// token otherwise
+	if username := config.Setting("auth_username", ""); username != "" {
+		generic.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+config.Token))
+	} else if config.Token != "" {
+		generic.auth = "Bearer " + config.Token
+	}
+
+	return generic, nil
+}
+
+func (generic *genericNotifier) Notify(ctx context.Context, message notifier.Message) (result notifier.Result, err error) {
+	message, err = message.Render(ctx)
+	if err != nil {
+		return
+	}
+	values := TemplateValues(message)
+
+	url, err := notifier.ParseTemplateUnredacted(generic.url, values)
+	if err != nil {
+		return
+	}
+
+	body, err := BuildBody(ctx, generic.body, message)
+	if err != nil {
+		return
+	}
+
+	if strings.Contains(generic.contentType, "json") && !json.Valid([]byte(body)) {
+		err = errors.New("Rendered body is not valid JSON: " + body)
+		return
+	}
+
+	sender := notifier.NewHttpSender("Webhook", generic.config)
+	for name, value := range generic.headers {
+		sender.Headers[name], err = notifier.ParseTemplateUnredacted(value, values)
+		if err != nil {
+			return
+		}
+	}
+	if generic.auth != "" {
+		sender.Headers["Authorization"] = generic.auth
+	}
+
+	_, err = sender.Send(ctx, generic.method, url, generic.contentType, []byte(body))
+	return
+}
+
+// Renders the body template. Without a template, the message is sent as JSON
+func BuildBody(ctx context.Context, bodyTemplate string, message notifier.Message) (string, error) {
+	if bodyTemplate == "" {
+		data, err := json.Marshal(Payload{
+			Title:  message.Title,
+			Text:   message.Text,
+			Status: message.Status,
+			Color:  notifier.HexColor(message.Color),
+			Fields: message.Fields,
+			Links:  message.Links,
+		})
+		return string(data), err
+	}
+
+	return notifier.ParseTemplateWith(ctx, bodyTemplate, TemplateValues(message))
+}
+
+// Fields of the rendered message, added to the template context along with
+// the environment variables
+func TemplateValues(message notifier.Message) map[string]interface{} {
+	return map[string]interface{}{
+		"Title":  message.Title,
+		"Text":   message.Text,
+		"Status": message.Status,
+		"Color":  notifier.HexColor(message.Color),
+		"Emoji":  message.Emoji(),
+		"Fields": message.Fields,
+		"Links":  message.Links,
+	}
+}
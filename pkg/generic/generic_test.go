@@ -0,0 +1,188 @@
+//go:build test
+// +build test
+
+package generic
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Request received by the stand-in endpoint
+type capturedRequest struct {
+	Method  string
+	Path    string
+	Headers http.Header
+	Body    string
+}
+
+// Starts a stand-in for the endpoint that records the requests
+func startEndpoint(capturedRequests *[]capturedRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		data, _ := io.ReadAll(request.Body)
+		*capturedRequests = append(*capturedRequests, capturedRequest{
+			Method:  request.Method,
+			Path:    request.URL.Path,
+			Headers: request.Header,
+			Body:    string(data),
+		})
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func TestNotifyWithBodyFile(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_NUMBER", "42")
+
+	var capturedRequests []capturedRequest
+	testServer := startEndpoint(&capturedRequests)
+	defer testServer.Close()
+
+	bodyFile := filepath.Join(test.TempDir(), "body.json")
+	os.WriteFile(bodyFile, []byte(`{
+		"summary": {{ printf "%s: %s" .DroneRepo .Text | toJson }},
+		"state": "{{ .Status }}",
+		"color": "{{ .Color }}",
+		"details": {
+			{{- range $index, $field := .Fields }}{{ if $index }},{{ end }}
+			{{ $field.Title | toJson }}: {{ $field.Value | toJson }}
+			{{- end }}
+		}
+	}`), 0644)
+
+	backend, err := notifier.New("generic", notifier.Config{
+		Webhook: testServer.URL + "/builds/{{.DroneBuildNumber}}",
+		Token:   "api-token",
+		Settings: map[string]string{
+			"method":    "put",
+			"body_file": bodyFile,
+			"headers":   "X-Build-Status: '{{.Status}}'\nX-Repo: '{{.DroneRepo}}'",
+		},
+	})
+	assert.Nil(test, err)
+
+	_, err = backend.Notify(context.Background(), notifier.Message{
+		Text:   "Build \"{{.DroneBuildNumber}}\" failed",
+		Status: "failed",
+		Fields: []notifier.Field{{Title: "Commit", Value: "abc123"}, {Title: "Branch", Value: "main"}},
+	})
+	assert.Nil(test, err)
+
+	assert.Equal(test, 1, len(capturedRequests))
+	assert.Equal(test, "PUT", capturedRequests[0].Method)
+	assert.Equal(test, "/builds/42", capturedRequests[0].Path)
+	assert.Equal(test, "Bearer api-token", capturedRequests[0].Headers.Get("Authorization"))
+	assert.Equal(test, "failure", capturedRequests[0].Headers.Get("X-Build-Status"))
+	assert.Equal(test, "octocat/hello-world", capturedRequests[0].Headers.Get("X-Repo"))
+	assert.Equal(test, "application/json", capturedRequests[0].Headers.Get("Content-Type"))
+	assert.JSONEq(test, `{
+		"summary": "octocat/hello-world: Build \"42\" failed",
+		"state": "failure",
+		"color": "#a1040c",
+		"details": {"Commit": "abc123", "Branch": "main"}
+	}`, capturedRequests[0].Body)
+}
+
+func TestNotifyWithDefaultBody(test *testing.T) {
+	var capturedRequests []capturedRequest
+	testServer := startEndpoint(&capturedRequests)
+	defer testServer.Close()
+
+	backend, err := notifier.New("generic", notifier.Config{
+		Webhook:  testServer.URL,
+		Token:    "secret",
+		Settings: map[string]string{"auth_username": "builds"},
+	})
+	assert.Nil(test, err)
+
+	_, err = backend.Notify(context.Background(), notifier.Message{
+		Title:  "Release",
+		Text:   "Build succeeded",
+		Status: "success",
+		Color:  "good",
+		Links:  []notifier.Link{{Text: "Open build", Url: "https://someurl"}},
+	})
+	assert.Nil(test, err)
+
+	assert.Equal(test, "POST", capturedRequests[0].Method)
+	assert.Equal(test, "Basic YnVpbGRzOnNlY3JldA==", capturedRequests[0].Headers.Get("Authorization"))
+	assert.JSONEq(test, `{
+		"title": "Release",
+		"text": "Build succeeded",
+		"status": "success",
+		"color": "#33ad7f",
+		"links": [{"text": "Open build", "url": "https://someurl"}]
+	}`, capturedRequests[0].Body)
+}
+
+func TestNotifyWithPlainTextBody(test *testing.T) {
+	var capturedRequests []capturedRequest
+	testServer := startEndpoint(&capturedRequests)
+	defer testServer.Close()
+
+	backend, err := notifier.New("generic", notifier.Config{
+		Webhook: testServer.URL,
+		Settings: map[string]string{
+			"body":         "{{.Emoji}} {{.Text}}",
+			"content_type": "text/plain",
+		},
+	})
+	assert.Nil(test, err)
+
+	_, err = backend.Notify(context.Background(), notifier.Message{Text: "Build failed", Status: "failure"})
+	assert.Nil(test, err)
+
+	assert.Equal(test, "", capturedRequests[0].Headers.Get("Authorization"))
+	assert.Equal(test, "text/plain", capturedRequests[0].Headers.Get("Content-Type"))
+	assert.Equal(test, "❌ Build failed", capturedRequests[0].Body)
+}
+
+func TestNotifyInvalidJson(test *testing.T) {
+	var capturedRequests []capturedRequest
+	testServer := startEndpoint(&capturedRequests)
+	defer testServer.Close()
+
+	backend, err := notifier.New("generic", notifier.Config{
+		Webhook:  testServer.URL,
+		Settings: map[string]string{"body": `{"text": "{{.Text}}"}`},
+	})
+	assert.Nil(test, err)
+
+	_, err = backend.Notify(context.Background(), notifier.Message{Text: `Build "42" failed`, Status: "failure"})
+	assert.Equal(test, `Rendered body is not valid JSON: {"text": "Build "42" failed"}`, err.Error())
+	assert.Equal(test, 0, len(capturedRequests))
+}
+
+func TestNewGenericNotifierErrors(test *testing.T) {
+	cases := []struct {
+		config   notifier.Config
+		expected string
+	}{
+		{
+			notifier.Config{},
+			"Webhook is required to post to a generic endpoint",
+		},
+		{
+			notifier.Config{Webhook: "https://someurl", Settings: map[string]string{"body_file": "/nonexistent/body.json"}},
+			"open /nonexistent/body.json: no such file or directory",
+		},
+		{
+			notifier.Config{Webhook: "https://someurl", Settings: map[string]string{"headers": "- X-Repo"}},
+			"json: cannot unmarshal array into Go value of type map[string]string",
+		},
+	}
+
+	for _, data := range cases {
+		_, err := notifier.New("generic", data.config)
+		assert.Equal(test, data.expected, err.Error())
+	}
+}
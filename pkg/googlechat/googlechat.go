@@ -0,0 +1,204 @@
+package googlechat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+)
+
+const provider string = "googlechat"
+
+// Replies to the thread with the key, starting a new thread if there isn't one
+const replyOption string = "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD"
+
+func init() {
+	notifier.Register(provider, newGoogleChatNotifier)
+}
+
+// Posts messages as cards to a Google Chat space webhook
+type googleChatNotifier struct {
+	sender    *notifier.HttpSender
+	webhook   string
+	threadKey string
+}
+
+// Message posted to the webhook
+type Payload struct {
+	CardsV2 []CardWithId `json:"cardsV2"`
+}
+
+type CardWithId struct {
+	CardId string `json:"cardId"`
+	Card   Card   `json:"card"`
+}
+
+type Card struct {
+	Header   *Header   `json:"header,omitempty"`
+	Sections []Section `json:"sections"`
+}
+
+type Header struct {
+	Title string `json:"title"`
+}
+
+type Section struct {
+	Widgets []Widget `json:"widgets"`
+}
+
+// A single widget, of which only one field is set
+type Widget struct {
+	TextParagraph *TextParagraph `json:"textParagraph,omitempty"`
+	DecoratedText *DecoratedText `json:"decoratedText,omitempty"`
+	ButtonList    *ButtonList    `json:"buttonList,omitempty"`
+}
+
+type TextParagraph struct {
+	Text string `json:"text"`
+}
+
+type DecoratedText struct {
+	TopLabel string `json:"topLabel,omitempty"`
+	Text     string `json:"text"`
+	WrapText bool   `json:"wrapText,omitempty"`
+}
+
+type ButtonList struct {
+	Buttons []Button `json:"buttons"`
+}
+
+type Button struct {
+	Text    string  `json:"text"`
+	OnClick OnClick `json:"onClick"`
+}
+
+type OnClick struct {
+	OpenLink OpenLink `json:"openLink"`
+}
+
+type OpenLink struct {
+	Url string `json:"url"`
+}
+
+// Response of the webhook, holding the posted message or the error
+type response struct {
+	Name  string `json:"name"` // Like spaces/AAA/messages/BBB
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newGoogleChatNotifier(config notifier.Config) (notifier.Notifier, error) {
+	if config.Webhook == "" {
+		return nil, errors.New("Webhook is required to post to Google Chat")
+	}
+
+	sender := notifier.NewHttpSender("Google Chat", config)
+	sender.ReadError = readError
+
+	return &googleChatNotifier{
+		sender:    sender,
+		webhook:   config.Webhook,
+		threadKey: config.Setting("thread_key", ""),
+	}, nil
+}
+
+func (googleChat *googleChatNotifier) Notify(ctx context.Context, message notifier.Message) (result notifier.Result, err error) {
+	payload, err := BuildPayload(ctx, message)
+	if err != nil {
+		return
+	}
+
+	webhook, err := googleChat.buildWebhookUrl()
+	if err != nil {
+		return
+	}
+
+	responseBody, err := googleChat.sender.PostJson(ctx, webhook, payload)
+	if err != nil {
+		return
+	}
+
+	googleChatResponse := response{}
+	if json.Unmarshal(responseBody, &googleChatResponse) == nil {
+		result.Id = googleChatResponse.Name
+		if index := strings.Index(result.Id, "/messages/"); index > 0 {
+			result.Channel = result.Id[:index]
+		}
+	}
+
+	return
+}
+
+// Adds the templated thread key to the webhook URL, if specified
+func (googleChat *googleChatNotifier) buildWebhookUrl() (string, error) {
+	threadKey, err := notifier.ParseTemplateUnredacted(googleChat.threadKey, nil)
+	if err != nil || threadKey == "" {
+		return googleChat.webhook, err
+	}
+
+	webhook, err := url.Parse(googleChat.webhook)
+	if err != nil {
+		return "", err
+	}
+
+	query := webhook.Query()
+	query.Set("threadKey", threadKey)
+	query.Set("messageReplyOption", replyOption)
+	webhook.RawQuery = query.Encode()
+
+	return webhook.String(), nil
+}
+
+// Renders the message as a card. The text is preceded by a dot in the status
+// color, as cards can't be colored. Fields are shown as labelled texts and
+// links as buttons
+func BuildPayload(ctx context.Context, message notifier.Message) (payload Payload, err error) {
+	message, err = message.Render(ctx)
+	if err != nil {
+		return
+	}
+
+	card := Card{}
+	if message.Title != "" {
+		card.Header = &Header{Title: message.Title}
+	}
+
+	widgets := []Widget{{
+		TextParagraph: &TextParagraph{
+			Text: `<font color="` + notifier.HexColor(message.Color) + `">●</font> ` + message.Text,
+		},
+	}}
+
+	for _, field := range message.Fields {
+		widgets = append(widgets, Widget{
+			DecoratedText: &DecoratedText{TopLabel: field.Title, Text: field.Value, WrapText: true},
+		})
+	}
+
+	if len(message.Links) > 0 {
+		buttonList := &ButtonList{}
+		for _, link := range message.Links {
+			buttonList.Buttons = append(buttonList.Buttons, Button{
+				Text:    link.Text,
+				OnClick: OnClick{OpenLink: OpenLink{Url: link.Url}},
+			})
+		}
+		widgets = append(widgets, Widget{ButtonList: buttonList})
+	}
+
+	card.Sections = []Section{{Widgets: widgets}}
+	payload.CardsV2 = []CardWithId{{CardId: "notification", Card: card}}
+	return
+}
+
+// Reads the reason from the JSON error body of Google's APIs
+func readError(deliveryError *notifier.DeliveryError, body []byte) {
+	googleChatResponse := response{}
+	if json.Unmarshal(body, &googleChatResponse) == nil && googleChatResponse.Error.Message != "" {
+		deliveryError.Reason = googleChatResponse.Error.Message
+	}
+}
@@ -0,0 +1,123 @@
+//go:build test
+// +build test
+
+package googlechat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "failure")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_LINK", "https://someurl")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+
+	// Test HTTP server
+	var capturedRequest []byte
+	var capturedQuery string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = io.ReadAll(request.Body)
+		capturedQuery = request.URL.RawQuery
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"name":"spaces/AAA/messages/BBB","thread":{"name":"spaces/AAA/threads/CCC"}}`)
+	}))
+	defer testServer.Close()
+
+	backend, err := notifier.New("googlechat", notifier.Config{
+		Webhook:  testServer.URL + "/v1/spaces/AAA/messages?key=secret",
+		Settings: map[string]string{"thread_key": "{{.DroneRepo}}"},
+	})
+	assert.Nil(test, err)
+
+	result, err := backend.Notify(context.Background(), notifier.Message{
+		Title:  "Build notification",
+		Text:   "Build {{.DroneBuildStatus}}",
+		Fields: []notifier.Field{{Title: "Branch", Value: "master"}},
+		Links:  []notifier.Link{{Text: "Open build", Url: "{{.DroneBuildLink}}"}},
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, notifier.Result{Channel: "spaces/AAA", Id: "spaces/AAA/messages/BBB"}, result)
+	assert.Equal(test, "key=secret&messageReplyOption=REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD&threadKey=octocat%2Fhello-world", capturedQuery)
+
+	expected := `{
+		"cardsV2": [{
+			"cardId": "notification",
+			"card": {
+				"header": {"title": "Build notification"},
+				"sections": [{
+					"widgets": [
+						{"textParagraph": {"text": "<font color=\"#a1040c\">●</font> Build failure"}},
+						{"decoratedText": {"topLabel": "Branch", "text": "master", "wrapText": true}},
+						{"buttonList": {"buttons": [{"text": "Open build", "onClick": {"openLink": {"url": "https://someurl"}}}]}}
+					]
+				}]
+			}
+		}]
+	}`
+	assert.JSONEq(test, expected, string(capturedRequest))
+}
+
+func TestBuildPayloadWithoutTitle(test *testing.T) {
+	payload, err := BuildPayload(context.Background(), notifier.Message{Text: "Build passed", Color: "good"})
+
+	assert.Nil(test, err)
+	assert.Equal(test, Payload{
+		CardsV2: []CardWithId{{
+			CardId: "notification",
+			Card: Card{
+				Sections: []Section{{
+					Widgets: []Widget{{TextParagraph: &TextParagraph{Text: `<font color="#33ad7f">●</font> Build passed`}}},
+				}},
+			},
+		}},
+	}, payload)
+}
+
+func TestNotifyWithoutThreadKey(test *testing.T) {
+	var capturedQuery string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedQuery = request.URL.RawQuery
+		fmt.Fprintln(writer, `{}`)
+	}))
+	defer testServer.Close()
+
+	backend, _ := notifier.New("googlechat", notifier.Config{Webhook: testServer.URL + "?key=secret"})
+	_, err := backend.Notify(context.Background(), notifier.Message{Text: "Build failed!"})
+
+	assert.Nil(test, err)
+	assert.Equal(test, "key=secret", capturedQuery)
+}
+
+func TestNotifyFailed(test *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(writer, `{"error":{"code":400,"message":"Invalid JSON payload"}}`)
+	}))
+	defer testServer.Close()
+
+	backend, _ := notifier.New("googlechat", notifier.Config{Webhook: testServer.URL})
+	_, err := backend.Notify(context.Background(), notifier.Message{Text: "Build failed!"})
+
+	var deliveryError *notifier.DeliveryError
+	assert.ErrorAs(test, err, &deliveryError)
+	assert.Equal(test, 400, deliveryError.StatusCode)
+	assert.False(test, deliveryError.Retryable)
+	assert.Equal(test, "HTTP request to Google Chat failed: Invalid JSON payload", err.Error())
+}
+
+func TestNewWithoutWebhook(test *testing.T) {
+	_, err := notifier.New("googlechat", notifier.Config{})
+
+	assert.Equal(test, "Webhook is required to post to Google Chat", err.Error())
+}
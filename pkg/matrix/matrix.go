@@ -0,0 +1,209 @@
+package matrix
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+)
+
+const provider string = "matrix"
+
+// Format of the HTML rendering of a message
+const htmlFormat string = "org.matrix.custom.html"
+
+func init() {
+	notifier.Register(provider, newMatrixNotifier)
+}
+
+// Sends messages to a Matrix room through the client-server API
+type matrixNotifier struct {
+	sender     *notifier.HttpSender
+	homeserver string
+	room       string
+}
+
+// Content of the m.room.message event
+type Event struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+// Response of the homeserver, holding the sent event, the resolved room or
+// the error
+type response struct {
+	EventId      string `json:"event_id,omitempty"`
+	RoomId       string `json:"room_id,omitempty"`
+	ErrorCode    string `json:"errcode,omitempty"`
+	Error        string `json:"error,omitempty"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+}
+
+func newMatrixNotifier(config notifier.Config) (notifier.Notifier, error) {
+	homeserver := config.Setting("homeserver", "")
+	if homeserver == "" || config.Token == "" {
+		return nil, errors.New("Homeserver and access token are required to send to Matrix")
+	}
+
+	if config.Channel == "" {
+		return nil, errors.New("Room ID or alias is required to send to Matrix")
+	}
+
+	sender := notifier.NewHttpSender("Matrix", config)
+	sender.ReadError = readError
+	sender.Headers["Authorization"] = "Bearer " + config.Token
+
+	return &matrixNotifier{
+		sender:     sender,
+		homeserver: strings.TrimSuffix(homeserver, "/"),
+		room:       config.Channel,
+	}, nil
+}
+
+func (matrix *matrixNotifier) Notify(ctx context.Context, message notifier.Message) (result notifier.Result, err error) {
+	event, err := BuildEvent(ctx, message)
+	if err != nil {
+		return
+	}
+
+	roomId, err := matrix.resolveRoom(ctx)
+	if err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(event)
+	// The transaction ID stays the same across retries, so that the
+	// homeserver drops a retry of an event it already received
+	eventUrl := matrix.homeserver + "/_matrix/client/v3/rooms/" + url.PathEscape(roomId) +
+		"/send/m.room.message/" + newTransactionId()
+
+	responseBody, err := matrix.sender.Send(ctx, "PUT", eventUrl, "application/json; charset=utf-8", data)
+	if err != nil {
+		return
+	}
+
+	matrixResponse := response{}
+	err = json.Unmarshal(responseBody, &matrixResponse)
+	if err != nil {
+		return
+	}
+
+	result.Channel = roomId
+	result.Id = matrixResponse.EventId
+	return
+}
+
+// Looks up the ID of the room when an alias like #builds:example.org is
+// specified
+func (matrix *matrixNotifier) resolveRoom(ctx context.Context) (string, error) {
+	if !strings.HasPrefix(matrix.room, "#") {
+		return matrix.room, nil
+	}
+
+	responseBody, err := matrix.sender.Send(
+		ctx,
+		"GET",
+		matrix.homeserver+"/_matrix/client/v3/directory/room/"+url.PathEscape(matrix.room),
+		"application/json",
+		nil,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	matrixResponse := response{}
+	err = json.Unmarshal(responseBody, &matrixResponse)
+	if err != nil {
+		return "", err
+	}
+
+	return matrixResponse.RoomId, nil
+}
+
+// Renders the message as a plain text body and an HTML formatted body. In
+// the formatted body, the title, or the text when there's no title, is
+// shown in the color of the message
+func BuildEvent(ctx context.Context, message notifier.Message) (event Event, err error) {
+	message, err = message.Render(ctx)
+	if err != nil {
+		return
+	}
+
+	plainLines := []string{}
+	htmlLines := []string{}
+	colorSpan := `<span data-mx-color="` + html.EscapeString(notifier.HexColor(message.Color)) + `">`
+
+	if message.Title != "" {
+		plainLines = append(plainLines, message.Title)
+		htmlLines = append(htmlLines, colorSpan+"<b>"+html.EscapeString(message.Title)+"</b></span>")
+	}
+
+	if message.Text != "" {
+		text := strings.ReplaceAll(html.EscapeString(message.Text), "\n", "<br>")
+		if message.Title == "" {
+			text = colorSpan + text + "</span>"
+		}
+
+		plainLines = append(plainLines, message.Text)
+		htmlLines = append(htmlLines, text)
+	}
+
+	if len(message.Fields) > 0 {
+		fieldItems := ""
+		for _, field := range message.Fields {
+			plainLines = append(plainLines, field.Title+": "+field.Value)
+			fieldItems += "<li><b>" + html.EscapeString(field.Title) + "</b>: " + html.EscapeString(field.Value) + "</li>"
+		}
+		htmlLines = append(htmlLines, "<ul>"+fieldItems+"</ul>")
+	}
+
+	if len(message.Links) > 0 {
+		links := []string{}
+		for _, link := range message.Links {
+			plainLines = append(plainLines, link.Text+": "+link.Url)
+			links = append(links, `<a href="`+html.EscapeString(link.Url)+`">`+html.EscapeString(link.Text)+"</a>")
+		}
+		htmlLines = append(htmlLines, strings.Join(links, " | "))
+	}
+
+	event = Event{
+		MsgType:       "m.text",
+		Body:          strings.Join(plainLines, "\n"),
+		Format:        htmlFormat,
+		FormattedBody: strings.Join(htmlLines, "<br>"),
+	}
+	return
+}
+
+// Generates a unique ID for an event
+func newTransactionId() string {
+	random := make([]byte, 8)
+	rand.Read(random)
+
+	return "simple-slack-" + hex.EncodeToString(random)
+}
+
+// Reads the error code and the delay requested on rate limits from the
+// JSON error body of the homeserver
+func readError(deliveryError *notifier.DeliveryError, body []byte) {
+	matrixResponse := response{}
+	if json.Unmarshal(body, &matrixResponse) == nil && matrixResponse.ErrorCode != "" {
+		deliveryError.Reason = matrixResponse.ErrorCode
+		if matrixResponse.Error != "" {
+			deliveryError.Reason += ": " + matrixResponse.Error
+		}
+
+		if matrixResponse.RetryAfterMs > 0 {
+			deliveryError.RetryAfter = time.Duration(matrixResponse.RetryAfterMs) * time.Millisecond
+		}
+	}
+}
@@ -0,0 +1,150 @@
+//go:build test
+// +build test
+
+package matrix
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "failure")
+
+	// Test homeserver
+	var capturedRequest []byte
+	var capturedPaths []string
+	var capturedAuthorization string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedPaths = append(capturedPaths, request.Method+" "+request.URL.EscapedPath())
+		capturedAuthorization = request.Header.Get("Authorization")
+		writer.Header().Set("Content-Type", "application/json")
+
+		if request.Method == "GET" {
+			fmt.Fprintln(writer, `{"room_id":"!abc:example.org","servers":["example.org"]}`)
+		} else {
+			capturedRequest, _ = io.ReadAll(request.Body)
+			fmt.Fprintln(writer, `{"event_id":"$event1"}`)
+		}
+	}))
+	defer testServer.Close()
+
+	backend, err := notifier.New("matrix", notifier.Config{
+		Token:    "secret-token",
+		Channel:  "#builds:example.org",
+		Settings: map[string]string{"homeserver": testServer.URL},
+	})
+	assert.Nil(test, err)
+
+	result, err := backend.Notify(context.Background(), notifier.Message{
+		Title:  "Build notification",
+		Text:   "Build {{.DroneBuildStatus}} <for> master",
+		Fields: []notifier.Field{{Title: "Branch", Value: "master"}},
+		Links:  []notifier.Link{{Text: "Open build", Url: "https://someurl?a=1&b=2"}},
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, notifier.Result{Channel: "!abc:example.org", Id: "$event1"}, result)
+	assert.Equal(test, "Bearer secret-token", capturedAuthorization)
+	assert.Equal(test, 2, len(capturedPaths))
+	assert.Equal(test, "GET /_matrix/client/v3/directory/room/%23builds:example.org", capturedPaths[0])
+	assert.True(test, strings.HasPrefix(capturedPaths[1], "PUT /_matrix/client/v3/rooms/%21abc:example.org/send/m.room.message/simple-slack-"))
+
+	expected := `{
+		"msgtype": "m.text",
+		"body": "Build notification\nBuild failure <for> master\nBranch: master\nOpen build: https://someurl?a=1&b=2",
+		"format": "org.matrix.custom.html",
+		"formatted_body": "<span data-mx-color=\"#a1040c\"><b>Build notification</b></span><br>Build failure &lt;for&gt; master<br><ul><li><b>Branch</b>: master</li></ul><br><a href=\"https://someurl?a=1&amp;b=2\">Open build</a>"
+	}`
+	assert.JSONEq(test, expected, string(capturedRequest))
+}
+
+func TestNotifyRetriesWithSameTransactionId(test *testing.T) {
+	var capturedPaths []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedPaths = append(capturedPaths, request.URL.Path)
+		writer.Header().Set("Content-Type", "application/json")
+
+		if len(capturedPaths) == 1 {
+			writer.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintln(writer, `{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":1}`)
+		} else {
+			fmt.Fprintln(writer, `{"event_id":"$event1"}`)
+		}
+	}))
+	defer testServer.Close()
+
+	backend, _ := notifier.New("matrix", notifier.Config{
+		Token:    "secret-token",
+		Channel:  "!abc:example.org",
+		Settings: map[string]string{"homeserver": testServer.URL},
+		Retry:    notifier.RetryPolicy{MaxRetries: 1},
+	})
+	result, err := backend.Notify(context.Background(), notifier.Message{Text: "Build failed!"})
+
+	assert.Nil(test, err)
+	assert.Equal(test, "$event1", result.Id)
+	assert.Equal(test, 2, len(capturedPaths))
+	assert.Equal(test, capturedPaths[0], capturedPaths[1])
+}
+
+func TestNotifyFailed(test *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusForbidden)
+		fmt.Fprintln(writer, `{"errcode":"M_FORBIDDEN","error":"User not in room"}`)
+	}))
+	defer testServer.Close()
+
+	backend, _ := notifier.New("matrix", notifier.Config{
+		Token:    "secret-token",
+		Channel:  "!abc:example.org",
+		Settings: map[string]string{"homeserver": testServer.URL},
+	})
+	_, err := backend.Notify(context.Background(), notifier.Message{Text: "Build failed!"})
+
+	var deliveryError *notifier.DeliveryError
+	assert.ErrorAs(test, err, &deliveryError)
+	assert.False(test, deliveryError.Retryable)
+	assert.Equal(test, "HTTP request to Matrix failed: M_FORBIDDEN: User not in room", err.Error())
+}
+
+func TestReadErrorRetryAfter(test *testing.T) {
+	deliveryError := &notifier.DeliveryError{StatusCode: http.StatusTooManyRequests, Retryable: true}
+
+	readError(deliveryError, []byte(`{"errcode":"M_LIMIT_EXCEEDED","retry_after_ms":1500}`))
+
+	assert.Equal(test, 1500*time.Millisecond, deliveryError.RetryAfter)
+	assert.Equal(test, "M_LIMIT_EXCEEDED", deliveryError.Reason)
+}
+
+func TestNewInvalidConfig(test *testing.T) {
+	_, err := notifier.New("matrix", notifier.Config{Channel: "!abc:example.org"})
+	assert.Equal(test, "Homeserver and access token are required to send to Matrix", err.Error())
+
+	_, err = notifier.New("matrix", notifier.Config{Token: "secret-token", Settings: map[string]string{"homeserver": "https://matrix"}})
+	assert.Equal(test, "Room ID or alias is required to send to Matrix", err.Error())
+}
+
+func TestBuildEventWithoutTitle(test *testing.T) {
+	event, err := BuildEvent(context.Background(), notifier.Message{Text: "Build\npassed", Color: "good"})
+
+	assert.Nil(test, err)
+	assert.Equal(test, Event{
+		MsgType:       "m.text",
+		Body:          "Build\npassed",
+		Format:        "org.matrix.custom.html",
+		FormattedBody: `<span data-mx-color="#33ad7f">Build<br>passed</span>`,
+	}, event)
+}
@@ -0,0 +1,168 @@
+package mattermost
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+)
+
+const provider string = "mattermost"
+
+// Message priorities
+const (
+	StandardPriority  string = "standard"
+	ImportantPriority string = "important"
+	UrgentPriority    string = "urgent"
+)
+
+func init() {
+	notifier.Register(provider, newMattermostNotifier)
+}
+
+// Posts messages to a Mattermost incoming webhook, with the features that
+// Mattermost adds to the Slack compatible payload
+type mattermostNotifier struct {
+	sender        *notifier.HttpSender
+	webhook       string
+	channel       string
+	username      string
+	iconUrl       string
+	card          string
+	priority      string
+	requestedAck  string
+	defaultBranch string
+}
+
+// Message posted to the webhook
+type Payload struct {
+	Channel     string       `json:"channel,omitempty"`
+	Username    string       `json:"username,omitempty"`
+	IconUrl     string       `json:"icon_url,omitempty"`
+	Text        string       `json:"text,omitempty"`
+	Attachments []Attachment `json:"attachments"`
+	Props       *Props       `json:"props,omitempty"`
+	Priority    *Priority    `json:"priority,omitempty"`
+}
+
+type Attachment struct {
+	Fallback string  `json:"fallback,omitempty"`
+	Color    string  `json:"color,omitempty"`
+	Title    string  `json:"title,omitempty"`
+	Text     string  `json:"text,omitempty"`
+	Fields   []Field `json:"fields,omitempty"`
+}
+
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// Extra details of the message. The card is shown in the sidebar when the
+// info icon of the message is clicked
+type Props struct {
+	Card string `json:"card,omitempty"`
+}
+
+type Priority struct {
+	Priority     string `json:"priority"`
+	RequestedAck bool   `json:"requested_ack,omitempty"`
+}
+
+func newMattermostNotifier(config notifier.Config) (notifier.Notifier, error) {
+	if config.Webhook == "" {
+		return nil, errors.New("Webhook is required to post to Mattermost")
+	}
+
+	return &mattermostNotifier{
+		sender:        notifier.NewHttpSender("Mattermost", config),
+		webhook:       config.Webhook,
+		channel:       config.Channel,
+		username:      config.Setting("username", ""),
+		iconUrl:       config.Setting("avatar_url", ""),
+		card:          config.Setting("card", ""),
+		priority:      config.Setting("priority", ""),
+		requestedAck:  config.Setting("requested_ack", ""),
+		defaultBranch: config.Setting("default_branch", ""),
+	}, nil
+}
+
+func (mattermost *mattermostNotifier) Notify(ctx context.Context, message notifier.Message) (result notifier.Result, err error) {
+	payload, err := BuildPayload(ctx, message)
+	if err != nil {
+		return
+	}
+	payload.Channel = mattermost.channel
+	payload.Username = mattermost.username
+	payload.IconUrl = mattermost.iconUrl
+
+	if mattermost.card != "" {
+		card, parseErr := notifier.ParseTemplate(ctx, mattermost.card)
+		if parseErr != nil {
+			return result, parseErr
+		}
+		payload.Props = &Props{Card: card}
+	}
+
+	payload.Priority = mattermost.buildPriority(message.ResolveStatus())
+
+	_, err = mattermost.sender.PostJson(ctx, mattermost.webhook, payload)
+	return
+}
+
+// Uses the specified priority if any. Otherwise, a failure on the default
+// branch is urgent and needs to be acknowledged, a failure on other branches
+// is important and other messages have the standard priority
+func (mattermost *mattermostNotifier) buildPriority(status string) *Priority {
+	priority := mattermost.priority
+	if priority == "" && status == notifier.StatusFailure {
+		priority = ImportantPriority
+
+		if notifier.CurrentBuild().OnDefaultBranch(mattermost.defaultBranch) {
+			priority = UrgentPriority
+		}
+	}
+
+	if priority == "" || priority == StandardPriority {
+		return nil
+	}
+
+	requestedAck, err := strconv.ParseBool(mattermost.requestedAck)
+	if err != nil {
+		requestedAck = priority == UrgentPriority
+	}
+
+	return &Priority{Priority: priority, RequestedAck: requestedAck}
+}
+
+// Renders the message as a colored attachment. Links are appended to the
+// text, as attachment buttons need an integration to handle them
+func BuildPayload(ctx context.Context, message notifier.Message) (payload Payload, err error) {
+	message, err = message.Render(ctx)
+	if err != nil {
+		return
+	}
+
+	attachment := Attachment{
+		Fallback: message.Text,
+		Color:    message.Color,
+		Title:    message.Title,
+		Text:     message.Text,
+	}
+
+	for _, link := range message.Links {
+		if attachment.Text != "" {
+			attachment.Text += "\n"
+		}
+		attachment.Text += "[" + link.Text + "](" + link.Url + ")"
+	}
+
+	for _, field := range message.Fields {
+		attachment.Fields = append(attachment.Fields, Field{Title: field.Title, Value: field.Value, Short: field.Short})
+	}
+
+	payload.Attachments = []Attachment{attachment}
+	return
+}
@@ -0,0 +1,103 @@
+//go:build test
+// +build test
+
+package mattermost
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "failure")
+	helper.SetEnvironmentVariable(test, "DRONE_COMMIT_BRANCH", "main")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO_BRANCH", "main")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_NUMBER", "42")
+
+	// Test HTTP server
+	var capturedRequest []byte
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = io.ReadAll(request.Body)
+		io.WriteString(writer, "ok")
+	}))
+	defer testServer.Close()
+
+	backend, err := notifier.New("mattermost", notifier.Config{
+		Webhook: testServer.URL,
+		Channel: "town-square",
+		Settings: map[string]string{
+			"username":   "CI",
+			"avatar_url": "https://avatar",
+			"card":       "Build {{.DroneBuildNumber}} details",
+		},
+	})
+	assert.Nil(test, err)
+
+	_, err = backend.Notify(context.Background(), notifier.Message{
+		Title:  "Build notification",
+		Text:   "Build {{.DroneBuildStatus}}",
+		Fields: []notifier.Field{{Title: "Branch", Value: "main", Short: true}},
+		Links:  []notifier.Link{{Text: "Open build", Url: "https://someurl"}},
+	})
+	assert.Nil(test, err)
+
+	expected := `{
+		"channel": "town-square",
+		"username": "CI",
+		"icon_url": "https://avatar",
+		"attachments": [{
+			"fallback": "Build failure",
+			"color": "#a1040c",
+			"title": "Build notification",
+			"text": "Build failure\n[Open build](https://someurl)",
+			"fields": [{"title": "Branch", "value": "main", "short": true}]
+		}],
+		"props": {"card": "Build 42 details"},
+		"priority": {"priority": "urgent", "requested_ack": true}
+	}`
+	assert.JSONEq(test, expected, string(capturedRequest))
+}
+
+func TestBuildPriority(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_COMMIT_BRANCH", "feature")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO_BRANCH", "main")
+
+	cases := []struct {
+		backend  mattermostNotifier
+		status   string
+		expected *Priority
+	}{
+		{mattermostNotifier{}, "failure", &Priority{Priority: "important"}},
+		{mattermostNotifier{defaultBranch: "feature"}, "failure", &Priority{Priority: "urgent", RequestedAck: true}},
+		{mattermostNotifier{defaultBranch: "feature", requestedAck: "false"}, "failure", &Priority{Priority: "urgent"}},
+		{mattermostNotifier{requestedAck: "true"}, "failure", &Priority{Priority: "important", RequestedAck: true}},
+		{mattermostNotifier{}, "success", nil},
+		{mattermostNotifier{priority: "urgent"}, "success", &Priority{Priority: "urgent", RequestedAck: true}},
+		{mattermostNotifier{priority: "standard"}, "failure", nil},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, data.backend.buildPriority(data.status))
+	}
+}
+
+func TestBuildPriorityUnknownBranch(test *testing.T) {
+	backend := mattermostNotifier{}
+
+	assert.Equal(test, &Priority{Priority: "important"}, backend.buildPriority("failure"))
+}
+
+func TestNewWithoutWebhook(test *testing.T) {
+	_, err := notifier.New("mattermost", notifier.Config{})
+
+	assert.Equal(test, "Webhook is required to post to Mattermost", err.Error())
+}
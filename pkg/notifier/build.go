@@ -0,0 +1,34 @@
+package notifier
+
+import (
+	"github.com/devatherock/simple-slack/pkg/ci"
+)
+
+// Details of the build being notified about, read from the CI environment
+type Build struct {
+	Repo          string // Full name of the repository, like octocat/hello-world
+	Branch        string // Branch being built
+	DefaultBranch string // Default branch of the repository, when known
+}
+
+// Reads the details of the build from the environment variables of Drone,
+// Vela or CircleCI
+func CurrentBuild() Build {
+	context := ci.Current()
+
+	return Build{
+		Repo:          context.Repo.Name,
+		Branch:        context.Branch,
+		DefaultBranch: context.Repo.DefaultBranch,
+	}
+}
+
+// Whether the build is of the default branch. The default branch can be
+// overridden, as not every CI system provides it
+func (build Build) OnDefaultBranch(defaultBranch string) bool {
+	if defaultBranch == "" {
+		defaultBranch = build.DefaultBranch
+	}
+
+	return build.Branch != "" && build.Branch == defaultBranch
+}
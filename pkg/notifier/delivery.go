@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/devatherock/simple-slack/internal/delivery"
+	log "github.com/sirupsen/logrus"
+)
+
+// Failed delivery of a message to a backend. Use errors.As to inspect it
+type DeliveryError = delivery.DeliveryError
+
+// Decides how failed deliveries are retried. The zero value disables retries
+type RetryPolicy = delivery.RetryPolicy
+
+// Creates an error for a request that didn't get a response, like a
+// connection failure or a timeout. The path of the URL is left out, as it
+// might hold a secret
+func NetworkError(provider string, err error) *DeliveryError {
+	return delivery.NetworkError(provider, err)
+}
+
+// Creates an error from a failed HTTP response. Rate limits and server
+// errors are retryable, while other client errors like an invalid payload
+// are not
+func ResponseError(provider string, res *http.Response) *DeliveryError {
+	return delivery.ResponseError(provider, res)
+}
+
+// Retries the send function as per the policy. Only a DeliveryError marked
+// as retryable is retried
+func Retry(ctx context.Context, policy RetryPolicy, logger log.FieldLogger, send func() error) error {
+	return delivery.Retry(ctx, policy, logger, send)
+}
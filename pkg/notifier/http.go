@@ -0,0 +1,93 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/devatherock/simple-slack/internal/delivery"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultTimeout time.Duration = 30 * time.Second
+
+// Sends requests to a backend over HTTP, retrying transient failures with
+// the retry policy of the config
+type HttpSender struct {
+	Provider   string
+	HttpClient *http.Client
+	Retry      RetryPolicy
+	Headers    map[string]string
+	ReadError  func(deliveryError *DeliveryError, body []byte) // Adds the details of the error body, for backends that don't use a plain text or Slack like body
+}
+
+// Creates a sender for the named backend from the config
+func NewHttpSender(provider string, config Config) *HttpSender {
+	httpClient := config.HttpClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	return &HttpSender{
+		Provider:   provider,
+		HttpClient: httpClient,
+		Retry:      config.Retry,
+		Headers:    make(map[string]string),
+	}
+}
+
+// Posts the payload as JSON and returns the response body
+func (sender *HttpSender) PostJson(ctx context.Context, url string, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return sender.Send(ctx, "POST", url, "application/json; charset=utf-8", data)
+}
+
+// Sends the body with the method and returns the response body. Error
+// responses are converted into a DeliveryError
+func (sender *HttpSender) Send(ctx context.Context, method string, url string, contentType string, body []byte) (responseBody []byte, err error) {
+	err = Retry(ctx, sender.Retry, log.StandardLogger(), func() (sendErr error) {
+		responseBody, sendErr = sender.send(ctx, method, url, contentType, body)
+		return
+	})
+
+	return
+}
+
+// Makes a single attempt at sending the body
+func (sender *HttpSender) send(ctx context.Context, method string, url string, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+	req.Header.Add("User-Agent", "simple-slack")
+	for name, value := range sender.Headers {
+		req.Header.Add(name, value)
+	}
+
+	res, err := sender.HttpClient.Do(req)
+	if err != nil {
+		return nil, NetworkError(sender.Provider, err)
+	}
+	defer res.Body.Close()
+	log.Info("Message sent to ", sender.Provider, " with http status ", res.StatusCode)
+
+	if res.StatusCode > 399 {
+		deliveryError, errorBody := delivery.ReadResponseError(sender.Provider, res)
+		if sender.ReadError != nil {
+			sender.ReadError(deliveryError, errorBody)
+		}
+
+		return nil, deliveryError
+	}
+
+	return io.ReadAll(res.Body)
+}
@@ -0,0 +1,179 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/devatherock/simple-slack/internal/textutil"
+	"github.com/devatherock/simple-slack/pkg/slack"
+	"gopkg.in/yaml.v3"
+)
+
+// Normalized build statuses
+const (
+	StatusSuccess string = slack.StatusSuccess
+	StatusFailure string = slack.StatusFailure
+	StatusRunning string = slack.StatusRunning
+)
+
+// Colors accepted by Slack by name, along with their common names
+var namedColors = map[string]string{
+	"good":    "#33ad7f",
+	"green":   "#33ad7f",
+	"warning": "#daa038",
+	"yellow":  "#daa038",
+	"danger":  "#a1040c",
+	"red":     "#a1040c",
+}
+
+// Emojis shown in place of the color, by backends without colors
+const (
+	successEmoji string = "✅"
+	failureEmoji string = "❌"
+	warningEmoji string = "⚠️"
+	runningEmoji string = "⏳"
+)
+
+// Backend neutral message. Text, field values and link URLs are templates,
+// processed by Render
+type Message struct {
+	Title  string  `json:",omitempty"`
+	Text   string  `json:",omitempty"`
+	Color  string  `json:",omitempty"` // Derived from the status when not specified
+	Status string  `json:",omitempty"` // Read from the CI environment when not specified
+	Fields []Field `json:",omitempty"`
+	Links  []Link  `json:",omitempty"`
+}
+
+// A labelled value, shown alongside the text
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short,omitempty"` // Whether the field can be shown side by side with other fields
+}
+
+// A link, shown as a button where the backend supports it
+type Link struct {
+	Text string `json:"text"`
+	Url  string `json:"url"`
+}
+
+// Processes the templates within the message and resolves its status and
+// color. Secrets, including those of the context, are redacted
+func (message Message) Render(ctx context.Context) (rendered Message, err error) {
+	rendered = message
+	rendered.Status = message.ResolveStatus()
+	rendered.Color = message.ResolveColor()
+
+	rendered.Text, err = ParseTemplate(ctx, message.Text)
+	if err != nil {
+		return
+	}
+
+	rendered.Fields = make([]Field, len(message.Fields))
+	for index, field := range message.Fields {
+		field.Value, err = ParseTemplate(ctx, field.Value)
+		if err != nil {
+			return
+		}
+		rendered.Fields[index] = field
+	}
+
+	rendered.Links = make([]Link, len(message.Links))
+	for index, link := range message.Links {
+		link.Url, err = ParseTemplate(ctx, link.Url)
+		if err != nil {
+			return
+		}
+		rendered.Links[index] = link
+	}
+
+	return
+}
+
+// Normalizes the status of the message, reading it from the CI environment
+// when not specified
+func (message Message) ResolveStatus() string {
+	if message.Status == "" {
+		return slack.BuildStatus()
+	}
+
+	return NormalizeStatus(message.Status)
+}
+
+// Uses the color of the message if specified and the color of its status
+// otherwise
+func (message Message) ResolveColor() string {
+	if message.Color != "" {
+		return message.Color
+	}
+
+	return slack.StatusColor(message.ResolveStatus())
+}
+
+// Picks the emoji matching the color of a rendered message, falling back to
+// its status for colors without a meaning
+func (message Message) Emoji() string {
+	switch strings.ToLower(HexColor(message.Color)) {
+	case slack.StatusColor(StatusSuccess):
+		return successEmoji
+	case slack.StatusColor(StatusFailure):
+		return failureEmoji
+	case HexColor("warning"):
+		return warningEmoji
+	}
+
+	if message.Status == StatusRunning {
+		return runningEmoji
+	}
+
+	return ""
+}
+
+// Converts a named color into its hex form, for backends that accept only
+// hex colors. Other colors are returned as is
+func HexColor(color string) string {
+	if hexColor, ok := namedColors[strings.ToLower(color)]; ok {
+		return hexColor
+	}
+
+	return color
+}
+
+// Maps the statuses reported by the supported CI systems to success,
+// failure or running. Unknown statuses are returned as is
+func NormalizeStatus(status string) string {
+	switch status {
+	case "success", "passed", "fixed":
+		return StatusSuccess
+	case "failure", "failed", "failing", "error", "errored", "killed":
+		return StatusFailure
+	case "running", "pending", "started":
+		return StatusRunning
+	default:
+		return status
+	}
+}
+
+// Reads a JSON or YAML document into the target, honouring its JSON tags
+func Decode(document string, target interface{}) error {
+	var content interface{}
+	err := yaml.Unmarshal([]byte(document), &content)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, target)
+}
+
+// Shortens the text to the limit, ending it with an ellipsis when cut. The
+// limit is counted in characters, like the limits of most backends
+func Truncate(text string, limit int) string {
+	return textutil.Truncate(text, limit)
+}
@@ -0,0 +1,92 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Name of the backend used when no provider is specified
+const DefaultProvider string = "slack"
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]Factory)
+)
+
+// Delivers messages to a chat or notification backend
+type Notifier interface {
+	Notify(ctx context.Context, message Message) (Result, error)
+}
+
+// Where and how to deliver messages. Settings holds the backend specific
+// parameters, keyed by the name of the plugin flag that supplies them
+type Config struct {
+	Webhook    string            `json:",omitempty"`
+	Token      string            `json:",omitempty"`
+	Channel    string            `json:",omitempty"`
+	Settings   map[string]string `json:",omitempty"`
+	Retry      RetryPolicy       `json:"-"`
+	HttpClient *http.Client      `json:"-"` // Defaults to a client with a 30 second timeout
+}
+
+// Identifies a delivered message, when the backend reports it
+type Result struct {
+	Channel string `json:"channel,omitempty"`
+	Id      string `json:"id,omitempty"`
+}
+
+// Creates a notifier from the config
+type Factory func(config Config) (Notifier, error)
+
+// Makes a backend available by name. Meant to be called from the init
+// function of the backend's package
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	registry[name] = factory
+}
+
+// Creates a notifier for the named backend. The default provider is used
+// when the name is empty
+func New(name string, config Config) (Notifier, error) {
+	if name == "" {
+		name = DefaultProvider
+	}
+
+	registryMutex.RLock()
+	factory, ok := registry[name]
+	registryMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("Unknown provider %s", name)
+	}
+
+	return factory(config)
+}
+
+// Lists the names of the registered backends, sorted
+func Providers() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	providers := make([]string, 0, len(registry))
+	for name := range registry {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+
+	return providers
+}
+
+// Reads a backend specific setting, falling back to the default value
+func (config Config) Setting(name string, defaultValue string) string {
+	if value := config.Settings[name]; value != "" {
+		return value
+	}
+
+	return defaultValue
+}
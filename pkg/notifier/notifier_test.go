@@ -0,0 +1,244 @@
+//go:build test
+// +build test
+
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devatherock/simple-slack/pkg/slack"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Records the messages it is asked to deliver
+type recordingNotifier struct {
+	config   Config
+	messages []Message
+}
+
+func (notifier *recordingNotifier) Notify(ctx context.Context, message Message) (Result, error) {
+	notifier.messages = append(notifier.messages, message)
+	return Result{Id: "1"}, nil
+}
+
+func TestRegistry(test *testing.T) {
+	recorder := &recordingNotifier{}
+	Register("recorder", func(config Config) (Notifier, error) {
+		recorder.config = config
+		return recorder, nil
+	})
+
+	backend, err := New("recorder", Config{Channel: "general"})
+	assert.Nil(test, err)
+
+	result, err := backend.Notify(context.Background(), Message{Text: "hello"})
+	assert.Nil(test, err)
+	assert.Equal(test, "1", result.Id)
+	assert.Equal(test, "general", recorder.config.Channel)
+	assert.Equal(test, []Message{{Text: "hello"}}, recorder.messages)
+
+	assert.Contains(test, Providers(), "recorder")
+	assert.Contains(test, Providers(), "slack")
+}
+
+func TestNewDefaultAndUnknownProvider(test *testing.T) {
+	backend, err := New("", Config{})
+	assert.Nil(test, err)
+	assert.IsType(test, &slackNotifier{}, backend)
+
+	_, err = New("carrier-pigeon", Config{})
+	assert.Equal(test, "Unknown provider carrier-pigeon", err.Error())
+}
+
+func TestSetting(test *testing.T) {
+	config := Config{Settings: map[string]string{"stream": "builds"}}
+
+	assert.Equal(test, "builds", config.Setting("stream", "general"))
+	assert.Equal(test, "general", config.Setting("topic", "general"))
+	assert.Equal(test, "general", Config{}.Setting("topic", "general"))
+}
+
+func TestRender(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "failure")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_LINK", "https://someurl")
+
+	message := Message{
+		Title:  "{{.DroneBuildStatus}}",
+		Text:   "Build {{.DroneBuildStatus}}",
+		Fields: []Field{{Title: "Status", Value: "{{.DroneBuildStatus}}", Short: true}},
+		Links:  []Link{{Text: "Open", Url: "{{.DroneBuildLink}}"}},
+	}
+
+	actual, err := message.Render(context.Background())
+
+	assert.Nil(test, err)
+	assert.Equal(test, Message{
+		Title:  "{{.DroneBuildStatus}}",
+		Text:   "Build failure",
+		Color:  "#a1040c",
+		Status: StatusFailure,
+		Fields: []Field{{Title: "Status", Value: "failure", Short: true}},
+		Links:  []Link{{Text: "Open", Url: "https://someurl"}},
+	}, actual)
+
+	// Input message should not be modified
+	assert.Equal(test, "{{.DroneBuildStatus}}", message.Fields[0].Value)
+}
+
+func TestResolveColor(test *testing.T) {
+	cases := []struct {
+		message  Message
+		expected string
+	}{
+		{Message{Color: "yellow", Status: "failed"}, "yellow"},
+		{Message{Status: "success"}, "#33ad7f"},
+		{Message{Status: "failing"}, "#a1040c"},
+		{Message{Status: "running"}, "#cfd3d7"},
+		{Message{}, "#cfd3d7"},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, data.message.ResolveColor())
+	}
+}
+
+func TestEmoji(test *testing.T) {
+	cases := []struct {
+		message  Message
+		expected string
+	}{
+		{Message{Color: "#33ad7f"}, "✅"},
+		{Message{Color: "good"}, "✅"},
+		{Message{Color: "#A1040C"}, "❌"},
+		{Message{Color: "warning"}, "⚠️"},
+		{Message{Color: "#cfd3d7", Status: "running"}, "⏳"},
+		{Message{Color: "#cfd3d7"}, ""},
+		{Message{Color: "#123456", Status: "success"}, ""},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, data.message.Emoji())
+	}
+}
+
+func TestNormalizeStatus(test *testing.T) {
+	cases := []struct{ status, expected string }{
+		{"success", StatusSuccess},
+		{"passed", StatusSuccess},
+		{"failed", StatusFailure},
+		{"failing", StatusFailure},
+		{"killed", StatusFailure},
+		{"error", StatusFailure},
+		{"running", StatusRunning},
+		{"pending", StatusRunning},
+		{"canceled", "canceled"},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, NormalizeStatus(data.status))
+	}
+}
+
+func TestDecode(test *testing.T) {
+	expected := []Field{
+		{Title: "Branch", Value: "master", Short: true},
+		{Title: "Author", Value: "octocat"},
+	}
+	cases := []string{
+		`[{"title":"Branch","value":"master","short":true},{"title":"Author","value":"octocat"}]`,
+		`
+- title: Branch
+  value: master
+  short: true
+- title: Author
+  value: octocat
+`,
+	}
+
+	for _, document := range cases {
+		var actual []Field
+		err := Decode(document, &actual)
+
+		assert.Nil(test, err)
+		assert.Equal(test, expected, actual)
+	}
+}
+
+func TestToSlackRequest(test *testing.T) {
+	config := Config{Webhook: "https://secreturl", Channel: "general"}
+
+	actual := ToSlackRequest(config, Message{Text: "Build failed!", Title: "Build", Status: "failed"})
+	assert.Equal(test, slack.SlackRequest{
+		Text:    "Build failed!",
+		Title:   "Build",
+		Color:   "#a1040c",
+		Channel: "general",
+		Webhook: "https://secreturl",
+	}, actual)
+
+	actual = ToSlackRequest(config, Message{
+		Text:   "Build failed!",
+		Fields: []Field{{Title: "Branch", Value: "master"}},
+		Links:  []Link{{Text: "Open", Url: "https://someurl"}},
+	})
+	assert.True(test, actual.ColorBar)
+	assert.Equal(test, []slack.Block{
+		{
+			Type: slack.SectionBlock,
+			Text: &slack.TextObject{Type: slack.Markdown, Text: "Build failed!"},
+		},
+		{
+			Type:   slack.SectionBlock,
+			Fields: []slack.TextObject{{Type: slack.Markdown, Text: "*Branch*\nmaster"}},
+		},
+		{
+			Type:     slack.ActionsBlock,
+			Elements: []slack.Element{{Type: slack.ButtonElement, Text: "Open", Url: "https://someurl"}},
+		},
+	}, actual.Blocks)
+}
+
+func TestSlackNotifier(test *testing.T) {
+	var capturedRequest []byte
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = io.ReadAll(request.Body)
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"ok":true,"channel":"C1234","ts":"1503435956.000247"}`)
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "SLACK_API_HOST", testServer.URL)
+
+	backend, _ := New("slack", Config{Token: "xoxb-secret", Channel: "general"})
+	result, err := backend.Notify(context.Background(), Message{Text: "Build failed!", Status: "failure"})
+
+	assert.Nil(test, err)
+	assert.Equal(test, Result{Channel: "C1234", Id: "1503435956.000247"}, result)
+
+	jsonRequest := make(map[string]interface{})
+	json.Unmarshal(capturedRequest, &jsonRequest)
+	attachment := jsonRequest["attachments"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(test, "#a1040c", attachment["color"])
+	assert.Equal(test, "Build failed!", attachment["text"])
+}
+
+func TestCurrentBuild(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "VELA", "true")
+	helper.SetEnvironmentVariable(test, "VELA_REPO_FULL_NAME", "octocat/hello-world")
+	helper.SetEnvironmentVariable(test, "VELA_BUILD_BRANCH", "main")
+	helper.SetEnvironmentVariable(test, "VELA_REPO_BRANCH", "main")
+
+	build := CurrentBuild()
+
+	assert.Equal(test, Build{Repo: "octocat/hello-world", Branch: "main", DefaultBranch: "main"}, build)
+	assert.True(test, build.OnDefaultBranch(""))
+	assert.False(test, build.OnDefaultBranch("master"))
+	assert.False(test, Build{}.OnDefaultBranch(""))
+}
@@ -0,0 +1,99 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/devatherock/simple-slack/pkg/slack"
+)
+
+// Slack allows at most 10 fields in a section block
+const maxSlackFields int = 10
+
+func init() {
+	Register("slack", newSlackNotifier)
+}
+
+// Posts messages to Slack or other chat clients with Slack compatible
+// webhooks
+type slackNotifier struct {
+	client *slack.Client
+	config Config
+}
+
+func newSlackNotifier(config Config) (Notifier, error) {
+	options := []slack.Option{}
+	if config.HttpClient != nil {
+		options = append(options, slack.WithHttpClient(config.HttpClient))
+	}
+
+	return &slackNotifier{
+		client: slack.NewClient(options...),
+		config: config,
+	}, nil
+}
+
+func (notifier *slackNotifier) Notify(ctx context.Context, message Message) (result Result, err error) {
+	response, err := notifier.client.Send(ctx, ToSlackRequest(notifier.config, message))
+	if err != nil {
+		return
+	}
+
+	result.Channel = response.Channel
+	result.Id = response.Timestamp
+	return
+}
+
+// Converts the message into a Slack request. Messages with fields or links
+// are sent as blocks within a colored attachment, as the legacy attachment
+// can't hold buttons
+func ToSlackRequest(config Config, message Message) slack.SlackRequest {
+	slackRequest := slack.SlackRequest{
+		Text:    message.Text,
+		Title:   message.Title,
+		Color:   message.ResolveColor(),
+		Channel: config.Channel,
+		Webhook: config.Webhook,
+		Token:   config.Token,
+		Retry:   config.Retry,
+	}
+
+	if len(message.Fields) == 0 && len(message.Links) == 0 {
+		return slackRequest
+	}
+
+	slackRequest.ColorBar = true
+	if message.Text != "" {
+		slackRequest.Blocks = append(slackRequest.Blocks, slack.Block{
+			Type: slack.SectionBlock,
+			Text: &slack.TextObject{Type: slack.Markdown, Text: message.Text},
+		})
+	}
+
+	for start := 0; start < len(message.Fields); start += maxSlackFields {
+		end := min(start+maxSlackFields, len(message.Fields))
+		block := slack.Block{Type: slack.SectionBlock}
+
+		for _, field := range message.Fields[start:end] {
+			block.Fields = append(block.Fields, slack.TextObject{
+				Type: slack.Markdown,
+				Text: "*" + field.Title + "*\n" + field.Value,
+			})
+		}
+		slackRequest.Blocks = append(slackRequest.Blocks, block)
+	}
+
+	if len(message.Links) > 0 {
+		block := slack.Block{Type: slack.ActionsBlock}
+
+		for _, link := range message.Links {
+			block.Elements = append(block.Elements, slack.Element{
+				Type: slack.ButtonElement,
+				Text: link.Text,
+				Url:  link.Url,
+			})
+		}
+		slackRequest.Blocks = append(slackRequest.Blocks, block)
+	}
+
+	return slackRequest
+}
@@ -0,0 +1,189 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Failure policies, deciding when sending a message to several targets
+// fails
+const (
+	FailOnAny string = "any"   // Fails when any of the targets fails
+	FailOnAll string = "all"   // Fails only when all of the targets fail
+	FailNever string = "never" // Never fails, the failures are only logged
+)
+
+// A destination of a message, when sending it to several destinations at
+// once. Unspecified values are taken from the common config
+type Target struct {
+	Name       string            `json:"name,omitempty"` // Label of the target in the results. Defaults to its position
+	Provider   string            `json:"provider,omitempty"`
+	Webhook    string            `json:"webhook,omitempty"`
+	WebhookEnv string            `json:"webhook_env,omitempty"` // Environment variable holding the webhook, like a CI secret
+	Token      string            `json:"token,omitempty"`
+	TokenEnv   string            `json:"token_env,omitempty"` // Environment variable holding the token, like a CI secret
+	Channel    string            `json:"channel,omitempty"`
+	Settings   map[string]string `json:"settings,omitempty"`
+}
+
+// Outcome of sending a message to a target
+type TargetResult struct {
+	Target string `json:"target"`
+	Result
+	Error string `json:"error,omitempty"`
+	Err   error  `json:"-"`
+}
+
+// Error reported when the targets that failed break the failure policy.
+// Holds the outcome of all the targets
+type TargetsError struct {
+	Results []TargetResult
+}
+
+func (targetsError *TargetsError) Error() string {
+	failures := []string{}
+	for _, result := range targetsError.Results {
+		if result.Err != nil {
+			failures = append(failures, result.Target+": "+result.Error)
+		}
+	}
+
+	return fmt.Sprintf("%d of %d targets failed. %s", len(failures), len(targetsError.Results), strings.Join(failures, "; "))
+}
+
+func (targetsError *TargetsError) Unwrap() []error {
+	errs := []error{}
+	for _, result := range targetsError.Results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+
+	return errs
+}
+
+// Checks that the failure policy is known. An empty policy means FailOnAny
+func ValidateFailurePolicy(policy string) error {
+	switch policy {
+	case "", FailOnAny, FailOnAll, FailNever:
+		return nil
+	default:
+		return fmt.Errorf("Unknown failure policy %s. Should be one of %s, %s or %s", policy, FailOnAny, FailOnAll, FailNever)
+	}
+}
+
+// Overlays the target on the common config of the provider. Settings of the
+// target take precedence over the common settings. The common webhook and
+// token are only inherited by targets of the same provider, so that a Slack
+// token isn't sent to a generic endpoint for example
+func (target Target) Apply(config Config, provider string) Config {
+	if target.Provider != "" && providerName(target.Provider) != providerName(provider) {
+		config.Webhook, config.Token = "", ""
+	}
+
+	if webhook := target.resolve(target.Webhook, target.WebhookEnv); webhook != "" {
+		config.Webhook = webhook
+	}
+	if token := target.resolve(target.Token, target.TokenEnv); token != "" {
+		config.Token = token
+	}
+	if target.Channel != "" {
+		config.Channel = target.Channel
+	}
+
+	settings := make(map[string]string)
+	for name, value := range config.Settings {
+		settings[name] = value
+	}
+	for name, value := range target.Settings {
+		settings[name] = value
+	}
+	config.Settings = settings
+
+	return config
+}
+
+// Name of the provider, where empty means the default provider
+func providerName(provider string) string {
+	if provider == "" {
+		return DefaultProvider
+	}
+
+	return provider
+}
+
+// Values of the target that must not show up in messages or logs
+func (target Target) Secrets() []string {
+	return []string{
+		target.resolve(target.Webhook, target.WebhookEnv),
+		target.resolve(target.Token, target.TokenEnv),
+		target.Settings["smtp_password"],
+	}
+}
+
+// Uses the value if specified and reads it from the environment variable
+// otherwise
+func (target Target) resolve(value string, envVariable string) string {
+	if value == "" && envVariable != "" {
+		return os.Getenv(envVariable)
+	}
+
+	return value
+}
+
+// Sends a message to each of the targets concurrently, through the send
+// function. The targets are named by their position when unnamed and the
+// results are in the order of the targets
+func FanOut(ctx context.Context, targets []Target, send func(ctx context.Context, target Target) (Result, error)) []TargetResult {
+	results := make([]TargetResult, len(targets))
+
+	var waitGroup sync.WaitGroup
+	for index, target := range targets {
+		if target.Name == "" {
+			target.Name = "target " + strconv.Itoa(index+1)
+		}
+
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			result, err := send(ctx, target)
+			results[index] = TargetResult{Target: target.Name, Result: result, Err: err}
+			if err != nil {
+				results[index].Error = err.Error()
+			}
+		}()
+	}
+	waitGroup.Wait()
+
+	return results
+}
+
+// Logs the outcome of each target and checks the failures against the
+// failure policy. The errors are logged with the context, so that its
+// secrets are redacted
+func CheckResults(ctx context.Context, policy string, results []TargetResult) error {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			log.WithContext(ctx).Error("Sending to ", result.Target, " failed: ", result.Err)
+			failed++
+		} else if result.Id != "" {
+			log.Info("Message sent to ", result.Target, ", to channel ", result.Channel, " with id ", result.Id)
+		} else {
+			log.Info("Message sent to ", result.Target)
+		}
+	}
+
+	if failed == 0 || policy == FailNever || (policy == FailOnAll && failed < len(results)) {
+		return nil
+	}
+
+	return &TargetsError{Results: results}
+}
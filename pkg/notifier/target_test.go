@@ -0,0 +1,164 @@
+//go:build test
+// +build test
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetApply(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "OPS_SLACK_TOKEN", "xoxb-ops")
+
+	config := Config{
+		Webhook:  "https://someurl",
+		Token:    "xoxb-default",
+		Channel:  "#general",
+		Settings: map[string]string{"username": "CI", "priority": "important"},
+	}
+
+	cases := []struct {
+		target   Target
+		expected Config
+	}{
+		{
+			Target{},
+			config,
+		},
+		{
+			Target{Channel: "#releases", TokenEnv: "OPS_SLACK_TOKEN", Settings: map[string]string{"priority": "urgent"}},
+			Config{
+				Webhook:  "https://someurl",
+				Token:    "xoxb-ops",
+				Channel:  "#releases",
+				Settings: map[string]string{"username": "CI", "priority": "urgent"},
+			},
+		},
+		{
+			Target{Webhook: "https://otherurl", WebhookEnv: "OPS_SLACK_TOKEN", Token: "xoxb-release"},
+			Config{
+				Webhook:  "https://otherurl",
+				Token:    "xoxb-release",
+				Channel:  "#general",
+				Settings: map[string]string{"username": "CI", "priority": "important"},
+			},
+		},
+		{
+			Target{Provider: "slack"},
+			config,
+		},
+		{
+			Target{Provider: "generic", Settings: map[string]string{"method": "PUT"}},
+			Config{
+				Channel:  "#general",
+				Settings: map[string]string{"username": "CI", "priority": "important", "method": "PUT"},
+			},
+		},
+		{
+			Target{Provider: "pagerduty", Token: "routing-key"},
+			Config{
+				Token:    "routing-key",
+				Channel:  "#general",
+				Settings: map[string]string{"username": "CI", "priority": "important"},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, data.target.Apply(config, ""))
+	}
+
+	// Targets of the common provider inherit the webhook and token
+	assert.Equal(test, config, Target{Provider: "teams"}.Apply(config, "teams"))
+	assert.Equal(test, config, Target{}.Apply(config, "teams"))
+
+	slackConfig := Target{Provider: "slack"}.Apply(config, "teams")
+	assert.Equal(test, "", slackConfig.Webhook)
+	assert.Equal(test, "", slackConfig.Token)
+
+	// The common settings are left as is
+	assert.Equal(test, "important", config.Settings["priority"])
+}
+
+func TestFanOut(test *testing.T) {
+	targets := []Target{
+		{Channel: "#general"},
+		{Name: "ops", Channel: "#ops"},
+		{Channel: "#releases"},
+	}
+
+	var running, maxRunning atomic.Int32
+	results := FanOut(context.Background(), targets, func(ctx context.Context, target Target) (Result, error) {
+		current := running.Add(1)
+		defer running.Add(-1)
+		if current > maxRunning.Load() {
+			maxRunning.Store(current)
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		if target.Name == "ops" {
+			return Result{}, &DeliveryError{Provider: "Slack", StatusCode: 404, Reason: "channel_not_found"}
+		}
+
+		return Result{Channel: target.Channel, Id: "1503435956.000247"}, nil
+	})
+
+	assert.Greater(test, maxRunning.Load(), int32(1))
+	assert.Equal(test, 3, len(results))
+	assert.Equal(test, TargetResult{Target: "target 1", Result: Result{Channel: "#general", Id: "1503435956.000247"}}, results[0])
+	assert.Equal(test, "ops", results[1].Target)
+	assert.Equal(test, "HTTP request to Slack failed: channel_not_found", results[1].Error)
+	assert.Equal(test, "target 3", results[2].Target)
+	assert.Equal(test, "#releases", results[2].Channel)
+}
+
+func TestCheckResults(test *testing.T) {
+	success := TargetResult{Target: "target 1"}
+	failure := TargetResult{Target: "ops", Err: errors.New("timeout"), Error: "timeout"}
+
+	cases := []struct {
+		policy   string
+		results  []TargetResult
+		expected string
+	}{
+		{"", []TargetResult{success, success}, ""},
+		{"", []TargetResult{success, failure}, "1 of 2 targets failed. ops: timeout"},
+		{FailOnAny, []TargetResult{success, failure}, "1 of 2 targets failed. ops: timeout"},
+		{FailOnAll, []TargetResult{success, failure}, ""},
+		{FailOnAll, []TargetResult{failure, failure}, "2 of 2 targets failed. ops: timeout; ops: timeout"},
+		{FailNever, []TargetResult{failure, failure}, ""},
+	}
+
+	for _, data := range cases {
+		err := CheckResults(context.Background(), data.policy, data.results)
+		if data.expected == "" {
+			assert.Nil(test, err)
+		} else {
+			assert.Equal(test, data.expected, err.Error())
+		}
+	}
+}
+
+func TestCheckResultsUnwrap(test *testing.T) {
+	deliveryError := &DeliveryError{StatusCode: 429, Retryable: true}
+	err := CheckResults(context.Background(), FailOnAny, []TargetResult{{Target: "ops", Err: deliveryError, Error: deliveryError.Error()}})
+
+	var actual *DeliveryError
+	assert.True(test, errors.As(err, &actual))
+	assert.Equal(test, deliveryError, actual)
+}
+
+func TestValidateFailurePolicy(test *testing.T) {
+	for _, policy := range []string{"", FailOnAny, FailOnAll, FailNever} {
+		assert.Nil(test, ValidateFailurePolicy(policy))
+	}
+
+	assert.Equal(test, "Unknown failure policy first. Should be one of any, all or never", ValidateFailurePolicy("first").Error())
+}
@@ -0,0 +1,30 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/devatherock/simple-slack/pkg/slack"
+)
+
+// Renders the template with the environment variables and the CI context.
+// Known secrets, including those of the context, are redacted
+func ParseTemplate(ctx context.Context, text string) (string, error) {
+	return slack.ParseTemplate(ctx, text)
+}
+
+// Form of ParseTemplate that adds the values to the template context, for
+// backends that expose computed fields like the resolved status
+func ParseTemplateWith(ctx context.Context, text string, values map[string]interface{}) (string, error) {
+	return slack.ParseTemplateWith(ctx, text, values)
+}
+
+// Form of ParseTemplateWith that keeps the secrets, for the parts of a
+// request that aren't shown to anyone, like the URL and the headers
+func ParseTemplateUnredacted(text string, values map[string]interface{}) (string, error) {
+	return slack.ParseTemplateUnredacted(text, values)
+}
+
+// Maps a normalized build status to its highlight color
+func StatusColor(status string) string {
+	return slack.StatusColor(status)
+}
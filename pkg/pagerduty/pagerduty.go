@@ -0,0 +1,215 @@
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	log "github.com/sirupsen/logrus"
+)
+
+const provider string = "pagerduty"
+
+// PagerDuty limits the summary to 1024 characters
+const maxSummaryLength int = 1024
+
+// Event actions
+const (
+	TriggerAction string = "trigger"
+	ResolveAction string = "resolve"
+)
+
+func init() {
+	notifier.Register(provider, newPagerDutyNotifier)
+}
+
+// Triggers PagerDuty incidents for failed builds, through the Events API v2,
+// and resolves them when a later build succeeds
+type pagerDutyNotifier struct {
+	sender        *notifier.HttpSender
+	routingKey    string
+	dedupKey      string
+	source        string
+	severity      string
+	defaultBranch string
+}
+
+// Event sent to the Events API
+type Event struct {
+	RoutingKey  string   `json:"routing_key"`
+	EventAction string   `json:"event_action"`
+	DedupKey    string   `json:"dedup_key"`
+	Client      string   `json:"client,omitempty"`
+	Payload     *Payload `json:"payload,omitempty"`
+	Links       []Link   `json:"links,omitempty"`
+}
+
+// Details of the incident, needed only to trigger one
+type Payload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+type Link struct {
+	Href string `json:"href"`
+	Text string `json:"text,omitempty"`
+}
+
+// Response of the Events API
+type response struct {
+	Status   string   `json:"status"`
+	Message  string   `json:"message"`
+	DedupKey string   `json:"dedup_key"`
+	Errors   []string `json:"errors"`
+}
+
+func newPagerDutyNotifier(config notifier.Config) (notifier.Notifier, error) {
+	if config.Token == "" {
+		return nil, errors.New("Routing key is required to send to PagerDuty")
+	}
+
+	sender := notifier.NewHttpSender("PagerDuty", config)
+	sender.ReadError = readError
+
+	return &pagerDutyNotifier{
+		sender:        sender,
+		routingKey:    config.Token,
+		dedupKey:      config.Setting("dedup_key", ""),
+		source:        config.Setting("source", ""),
+		severity:      config.Setting("severity", ""),
+		defaultBranch: config.Setting("default_branch", ""),
+	}, nil
+}
+
+// Triggers an incident on failure and resolves it on success. Messages with
+// other statuses, like running, are skipped
+func (pagerDuty *pagerDutyNotifier) Notify(ctx context.Context, message notifier.Message) (result notifier.Result, err error) {
+	event, err := pagerDuty.buildEvent(ctx, message)
+	if err != nil {
+		return
+	}
+
+	if event.EventAction == "" {
+		log.Info("Skipped PagerDuty event for status '", message.ResolveStatus(), "'")
+		return
+	}
+
+	responseBody, err := pagerDuty.sender.PostJson(ctx, getPagerDutyApiUrl()+"/v2/enqueue", event)
+	if err != nil {
+		return
+	}
+
+	pagerDutyResponse := response{}
+	if json.Unmarshal(responseBody, &pagerDutyResponse) == nil {
+		result.Id = pagerDutyResponse.DedupKey
+	}
+
+	return
+}
+
+// Builds the event for the status of the message. The dedup key ties the
+// resolve event to the incident triggered earlier, so it defaults to the
+// repository and branch of the build
+func (pagerDuty *pagerDutyNotifier) buildEvent(ctx context.Context, message notifier.Message) (event Event, err error) {
+	message, err = message.Render(ctx)
+	if err != nil {
+		return
+	}
+
+	switch message.Status {
+	case notifier.StatusFailure:
+		event.EventAction = TriggerAction
+	case notifier.StatusSuccess:
+		event.EventAction = ResolveAction
+	default:
+		return
+	}
+
+	build := notifier.CurrentBuild()
+	event.RoutingKey = pagerDuty.routingKey
+	event.Client = "simple-slack"
+
+	event.DedupKey, err = notifier.ParseTemplate(ctx, pagerDuty.dedupKey)
+	if err != nil {
+		return
+	}
+	if event.DedupKey == "" {
+		event.DedupKey = strings.Trim(build.Repo+"/"+build.Branch, "/")
+	}
+	if event.DedupKey == "" {
+		err = errors.New("Dedup key is required to send to PagerDuty, as the repository and branch are unknown")
+		return
+	}
+
+	// Only a triggered incident takes details
+	if event.EventAction == ResolveAction {
+		return
+	}
+
+	summary := message.Text
+	if message.Title != "" {
+		summary = message.Title + ": " + summary
+	}
+
+	source := pagerDuty.source
+	if source == "" {
+		source = event.DedupKey
+	}
+
+	event.Payload = &Payload{
+		Summary:  notifier.Truncate(summary, maxSummaryLength),
+		Source:   source,
+		Severity: pagerDuty.mapSeverity(build),
+	}
+
+	if len(message.Fields) > 0 {
+		event.Payload.CustomDetails = make(map[string]string)
+		for _, field := range message.Fields {
+			event.Payload.CustomDetails[field.Title] = field.Value
+		}
+	}
+
+	for _, link := range message.Links {
+		event.Links = append(event.Links, Link{Href: link.Url, Text: link.Text})
+	}
+
+	return
+}
+
+// Uses the specified severity if any. Otherwise, a failure on the default
+// branch is critical and a failure on other branches is an error
+func (pagerDuty *pagerDutyNotifier) mapSeverity(build notifier.Build) string {
+	if pagerDuty.severity != "" {
+		return pagerDuty.severity
+	}
+
+	if build.OnDefaultBranch(pagerDuty.defaultBranch) {
+		return "critical"
+	}
+
+	return "error"
+}
+
+// Reads the Events API URL from PAGERDUTY_API_HOST environment variable
+func getPagerDutyApiUrl() (pagerDutyApiUrl string) {
+	pagerDutyApiUrl = os.Getenv("PAGERDUTY_API_HOST")
+
+	if pagerDutyApiUrl == "" {
+		pagerDutyApiUrl = "https://events.pagerduty.com"
+	}
+
+	return
+}
+
+// Reads the reasons from the JSON error body of the Events API
+func readError(deliveryError *notifier.DeliveryError, body []byte) {
+	pagerDutyResponse := response{}
+	if json.Unmarshal(body, &pagerDutyResponse) == nil && pagerDutyResponse.Message != "" {
+		deliveryError.Reason = strings.Join(append([]string{pagerDutyResponse.Message}, pagerDutyResponse.Errors...), ", ")
+	}
+}
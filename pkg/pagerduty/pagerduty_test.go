@@ -0,0 +1,178 @@
+//go:build test
+// +build test
+
+package pagerduty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Starts a stand-in for the Events API that records the events
+func startEventsApi(test *testing.T, capturedRequests *[]string) *httptest.Server {
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		data, _ := io.ReadAll(request.Body)
+		*capturedRequests = append(*capturedRequests, request.URL.Path+" "+string(data))
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(writer, `{"status":"success","message":"Event processed","dedup_key":"octocat/hello-world/main"}`)
+	}))
+	helper.SetEnvironmentVariable(test, "PAGERDUTY_API_HOST", testServer.URL)
+
+	return testServer
+}
+
+func TestNotifyTriggerAndResolve(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+	helper.SetEnvironmentVariable(test, "DRONE_COMMIT_BRANCH", "main")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO_BRANCH", "main")
+
+	var capturedRequests []string
+	testServer := startEventsApi(test, &capturedRequests)
+	defer testServer.Close()
+
+	backend, err := notifier.New("pagerduty", notifier.Config{Token: "routing-key"})
+	assert.Nil(test, err)
+
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "failure")
+	result, err := backend.Notify(context.Background(), notifier.Message{
+		Title:  "Release pipeline",
+		Text:   "Build {{.DroneBuildStatus}}",
+		Fields: []notifier.Field{{Title: "Commit", Value: "abc123"}},
+		Links:  []notifier.Link{{Text: "Open build", Url: "https://someurl"}},
+	})
+	assert.Nil(test, err)
+	assert.Equal(test, "octocat/hello-world/main", result.Id)
+
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "success")
+	_, err = backend.Notify(context.Background(), notifier.Message{Text: "Build {{.DroneBuildStatus}}"})
+	assert.Nil(test, err)
+
+	assert.Equal(test, 2, len(capturedRequests))
+	assert.Equal(test, "/v2/enqueue", capturedRequests[0][:11])
+	assert.JSONEq(test, `{
+		"routing_key": "routing-key",
+		"event_action": "trigger",
+		"dedup_key": "octocat/hello-world/main",
+		"client": "simple-slack",
+		"payload": {
+			"summary": "Release pipeline: Build failure",
+			"source": "octocat/hello-world/main",
+			"severity": "critical",
+			"custom_details": {"Commit": "abc123"}
+		},
+		"links": [{"href": "https://someurl", "text": "Open build"}]
+	}`, capturedRequests[0][12:])
+	assert.JSONEq(test, `{
+		"routing_key": "routing-key",
+		"event_action": "resolve",
+		"dedup_key": "octocat/hello-world/main",
+		"client": "simple-slack"
+	}`, capturedRequests[1][12:])
+}
+
+func TestNotifySkipsOtherStatuses(test *testing.T) {
+	var capturedRequests []string
+	testServer := startEventsApi(test, &capturedRequests)
+	defer testServer.Close()
+
+	backend, _ := notifier.New("pagerduty", notifier.Config{Token: "routing-key"})
+	result, err := backend.Notify(context.Background(), notifier.Message{Text: "Build running", Status: "running"})
+
+	assert.Nil(test, err)
+	assert.Equal(test, notifier.Result{}, result)
+	assert.Equal(test, 0, len(capturedRequests))
+}
+
+func TestBuildEvent(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+	helper.SetEnvironmentVariable(test, "DRONE_COMMIT_BRANCH", "feature")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO_BRANCH", "main")
+
+	cases := []struct {
+		backend  pagerDutyNotifier
+		expected Event
+	}{
+		{
+			pagerDutyNotifier{routingKey: "key"},
+			Event{
+				RoutingKey:  "key",
+				EventAction: "trigger",
+				DedupKey:    "octocat/hello-world/feature",
+				Client:      "simple-slack",
+				Payload:     &Payload{Summary: "Build failed", Source: "octocat/hello-world/feature", Severity: "error"},
+			},
+		},
+		{
+			pagerDutyNotifier{routingKey: "key", dedupKey: "release-{{.DroneRepo}}", source: "drone", severity: "warning"},
+			Event{
+				RoutingKey:  "key",
+				EventAction: "trigger",
+				DedupKey:    "release-octocat/hello-world",
+				Client:      "simple-slack",
+				Payload:     &Payload{Summary: "Build failed", Source: "drone", Severity: "warning"},
+			},
+		},
+		{
+			pagerDutyNotifier{routingKey: "key", defaultBranch: "feature"},
+			Event{
+				RoutingKey:  "key",
+				EventAction: "trigger",
+				DedupKey:    "octocat/hello-world/feature",
+				Client:      "simple-slack",
+				Payload:     &Payload{Summary: "Build failed", Source: "octocat/hello-world/feature", Severity: "critical"},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		actual, err := data.backend.buildEvent(context.Background(), notifier.Message{Text: "Build failed", Status: "failed"})
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual)
+	}
+}
+
+func TestBuildEventWithoutDedupKey(test *testing.T) {
+	backend := pagerDutyNotifier{routingKey: "key"}
+	_, err := backend.buildEvent(context.Background(), notifier.Message{Text: "Build failed", Status: "failure"})
+
+	assert.Equal(test, "Dedup key is required to send to PagerDuty, as the repository and branch are unknown", err.Error())
+}
+
+func TestNotifyFailed(test *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(writer, `{"status":"invalid event","message":"Event object is invalid","errors":["Length of 'routing_key' is incorrect (should be 32 characters)"]}`)
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "PAGERDUTY_API_HOST", testServer.URL)
+
+	backend, _ := notifier.New("pagerduty", notifier.Config{
+		Token:    "routing-key",
+		Settings: map[string]string{"dedup_key": "release"},
+	})
+	_, err := backend.Notify(context.Background(), notifier.Message{Text: "Build failed", Status: "failure"})
+
+	var deliveryError *notifier.DeliveryError
+	assert.ErrorAs(test, err, &deliveryError)
+	assert.False(test, deliveryError.Retryable)
+	assert.Equal(test, "HTTP request to PagerDuty failed: Event object is invalid, Length of 'routing_key' is incorrect (should be 32 characters)", err.Error())
+}
+
+func TestNewWithoutRoutingKey(test *testing.T) {
+	_, err := notifier.New("pagerduty", notifier.Config{})
+
+	assert.Equal(test, "Routing key is required to send to PagerDuty", err.Error())
+}
@@ -0,0 +1,176 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported Block Kit block types
+const (
+	HeaderBlock  string = "header"
+	SectionBlock string = "section"
+	ContextBlock string = "context"
+	DividerBlock string = "divider"
+	ImageBlock   string = "image"
+	ActionsBlock string = "actions"
+)
+
+// Supported Block Kit element and text object types
+const (
+	PlainText     string = "plain_text"
+	Markdown      string = "mrkdwn"
+	ImageElement  string = "image"
+	ButtonElement string = "button"
+)
+
+// A Block Kit layout block. Only the fields relevant to the block type need
+// to be specified
+type Block struct {
+	Type     string       `json:"type"`
+	BlockId  string       `json:"block_id,omitempty"`
+	Text     *TextObject  `json:"text,omitempty"`
+	Fields   []TextObject `json:"fields,omitempty"`
+	Elements []Element    `json:"elements,omitempty"`
+	ImageUrl string       `json:"image_url,omitempty"`
+	AltText  string       `json:"alt_text,omitempty"`
+	Title    *TextObject  `json:"title,omitempty"`
+}
+
+// A Block Kit text object
+type TextObject struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// An element of a context or actions block. Text is sent as a plain string
+// for text elements and as a plain_text object for buttons
+type Element struct {
+	Type     string `json:"type"`
+	Text     string `json:"-"`
+	Url      string `json:"url,omitempty"`
+	Style    string `json:"style,omitempty"`
+	Value    string `json:"value,omitempty"`
+	ActionId string `json:"action_id,omitempty"`
+	ImageUrl string `json:"image_url,omitempty"`
+	AltText  string `json:"alt_text,omitempty"`
+}
+
+// Serializes the element in the shape expected by Slack for its type
+func (element Element) MarshalJSON() ([]byte, error) {
+	type plainElement Element
+
+	var text interface{}
+	if element.Text != "" {
+		if element.Type == ButtonElement {
+			text = TextObject{Type: PlainText, Text: element.Text}
+		} else {
+			text = element.Text
+		}
+	}
+
+	return json.Marshal(struct {
+		plainElement
+		Text interface{} `json:"text,omitempty"`
+	}{plainElement(element), text})
+}
+
+// Reads an element whose text is either a plain string or a text object
+func (element *Element) UnmarshalJSON(data []byte) error {
+	type plainElement Element
+
+	wrapper := struct {
+		*plainElement
+		Text json.RawMessage `json:"text,omitempty"`
+	}{plainElement: (*plainElement)(element)}
+
+	err := json.Unmarshal(data, &wrapper)
+	if err != nil || len(wrapper.Text) == 0 {
+		return err
+	}
+
+	if wrapper.Text[0] == '{' {
+		textObject := TextObject{}
+		err = json.Unmarshal(wrapper.Text, &textObject)
+		element.Text = textObject.Text
+		return err
+	}
+
+	return json.Unmarshal(wrapper.Text, &element.Text)
+}
+
+// Reads blocks from a JSON or YAML document. The document can either be a
+// list of blocks or an object with a blocks key, as exported by the Block
+// Kit Builder
+func parseBlocks(document string) (blocks []Block, err error) {
+	var content interface{}
+	err = yaml.Unmarshal([]byte(document), &content)
+	if err != nil {
+		return
+	}
+
+	if wrapper, ok := content.(map[string]interface{}); ok {
+		content = wrapper["blocks"]
+	}
+
+	// Round trip through JSON so that the custom unmarshalers apply
+	data, err := json.Marshal(content)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &blocks)
+	return
+}
+
+// Processes every text, URL and alt text within the blocks as a template
+func renderBlocks(ctx context.Context, blocks []Block) ([]Block, error) {
+	rendered := make([]Block, len(blocks))
+
+	for index, block := range blocks {
+		var err error
+		fields := []*string{&block.ImageUrl, &block.AltText}
+
+		if block.Text != nil {
+			text := *block.Text
+			block.Text = &text
+			fields = append(fields, &block.Text.Text)
+		}
+
+		if block.Title != nil {
+			title := *block.Title
+			block.Title = &title
+			fields = append(fields, &block.Title.Text)
+		}
+
+		if block.Fields != nil {
+			block.Fields = append([]TextObject(nil), block.Fields...)
+			for fieldIndex := range block.Fields {
+				fields = append(fields, &block.Fields[fieldIndex].Text)
+			}
+		}
+
+		if block.Elements != nil {
+			block.Elements = append([]Element(nil), block.Elements...)
+			for elementIndex := range block.Elements {
+				element := &block.Elements[elementIndex]
+				fields = append(fields, &element.Text, &element.Url, &element.ImageUrl, &element.AltText)
+			}
+		}
+
+		for _, field := range fields {
+			if *field != "" {
+				*field, err = parseTemplate(ctx, *field)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		rendered[index] = block
+	}
+
+	return rendered, nil
+}
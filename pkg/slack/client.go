@@ -0,0 +1,179 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultTimeout time.Duration = 30 * time.Second
+const defaultUserAgent string = "simple-slack"
+
+// Used by the package level functions
+var defaultClient = NewClient()
+
+// Posts messages to Slack. Safe for concurrent use
+type Client struct {
+	httpClient *http.Client
+	baseUrl    string
+	userAgent  string
+	logger     log.FieldLogger
+}
+
+// Configures a Client
+type Option func(*Client)
+
+// Creates a client with a 30 second timeout, customized by the options
+func NewClient(options ...Option) *Client {
+	client := &Client{
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		userAgent:  defaultUserAgent,
+		logger:     log.StandardLogger(),
+	}
+
+	for _, option := range options {
+		option(client)
+	}
+
+	return client
+}
+
+// Uses the supplied HTTP client, to allow a custom transport, proxy or tracing
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+// Sets the timeout of each HTTP request. Applied to a copy of the HTTP client
+func WithTimeout(timeout time.Duration) Option {
+	return func(client *Client) {
+		httpClient := *client.httpClient
+		httpClient.Timeout = timeout
+		client.httpClient = &httpClient
+	}
+}
+
+// Sets the URL of the Slack Web API. Defaults to SLACK_API_HOST environment
+// variable or https://slack.com
+func WithBaseUrl(baseUrl string) Option {
+	return func(client *Client) {
+		client.baseUrl = baseUrl
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(client *Client) {
+		client.userAgent = userAgent
+	}
+}
+
+func WithLogger(logger log.FieldLogger) Option {
+	return func(client *Client) {
+		client.logger = logger
+	}
+}
+
+// Posts the message to Slack, using the Web API if a token is specified and
+// the webhook otherwise. Rocket.Chat's REST API is used when a Rocket.Chat
+// token is specified. When a timestamp is specified, the message with that
+// timestamp is updated instead. The channel ID and timestamp of the message
+// are returned only for the Web API, as webhooks don't provide them. Pending
+// requests and retries are abandoned when the context is cancelled
+func (client *Client) Send(ctx context.Context, request SlackRequest) (response SlackResponse, err error) {
+	err = Validate(request)
+	if err != nil {
+		return
+	}
+
+	payload, err := buildPayload(ctx, request)
+	if err != nil {
+		return
+	}
+
+	if request.ThreadTs != "" {
+		payload["thread_ts"] = request.ThreadTs
+
+		if request.ReplyBroadcast {
+			payload["reply_broadcast"] = true
+		}
+	}
+
+	if request.RocketChatToken != "" {
+		err = client.sendWithRetry(ctx, request.Retry, func() (sendErr error) {
+			response, sendErr = client.postToRocketChat(ctx, request, payload)
+			return
+		})
+		return
+	}
+
+	if request.Token != "" {
+		method := "chat.postMessage"
+		if request.Timestamp != "" {
+			method = "chat.update"
+			payload["ts"] = request.Timestamp
+		}
+
+		data, _ := json.Marshal(payload)
+		err = client.sendWithRetry(ctx, request.Retry, func() (sendErr error) {
+			response, sendErr = client.callWebApi(ctx, method, request.Token, data)
+			return
+		})
+		return
+	}
+
+	data, _ := json.Marshal(payload)
+	err = client.sendWithRetry(ctx, request.Retry, func() error {
+		return client.postToWebhook(ctx, request.Webhook, data)
+	})
+	return
+}
+
+// Posts the payload to an incoming webhook
+func (client *Client) postToWebhook(ctx context.Context, webhook string, data []byte) error {
+	req, err := client.newRequest(ctx, webhook, data)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.httpClient.Do(req)
+	if err != nil {
+		return newNetworkError(err)
+	}
+	defer res.Body.Close()
+	client.logger.Info("Message posted to webhook with http status ", res.StatusCode)
+
+	if res.StatusCode > 399 {
+		return newResponseError(res)
+	}
+
+	return nil
+}
+
+// Creates a JSON POST request
+func (client *Client) newRequest(ctx context.Context, url string, data []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+	if client.userAgent != "" {
+		req.Header.Add("User-Agent", client.userAgent)
+	}
+
+	return req, nil
+}
+
+// Resolves the URL of the Slack Web API
+func (client *Client) getBaseUrl() string {
+	if client.baseUrl != "" {
+		return client.baseUrl
+	}
+
+	return getSlackApiUrl()
+}
@@ -0,0 +1,107 @@
+//go:build test
+// +build test
+
+package slack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// Counts the requests that pass through it
+type countingTransport struct {
+	requests int
+}
+
+func (transport *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	transport.requests++
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func TestClientOptions(test *testing.T) {
+	var userAgent, path string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		io.ReadAll(request.Body)
+		userAgent = request.Header.Get("User-Agent")
+		path = request.URL.Path
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"ok":true,"channel":"C1234","ts":"1503435956.000247"}`)
+	}))
+	defer testServer.Close()
+
+	transport := &countingTransport{}
+	logger, hook := logtest.NewNullLogger()
+	client := NewClient(
+		WithHttpClient(&http.Client{Transport: transport}),
+		WithTimeout(5*time.Second),
+		WithBaseUrl(testServer.URL+"/slack"),
+		WithUserAgent("my-agent"),
+		WithLogger(logger),
+	)
+
+	response, err := client.Send(context.Background(), SlackRequest{
+		Text:    "Build failed!",
+		Channel: "general",
+		Token:   "xoxb-secret",
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, "1503435956.000247", response.Timestamp)
+	assert.Equal(test, "my-agent", userAgent)
+	assert.Equal(test, "/slack/api/chat.postMessage", path)
+	assert.Equal(test, 1, transport.requests)
+	assert.Equal(test, 5*time.Second, client.httpClient.Timeout)
+	assert.Equal(test, 1, len(hook.Entries))
+	assert.Equal(test, log.InfoLevel, hook.LastEntry().Level)
+}
+
+func TestClientTimeout(test *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer testServer.Close()
+
+	client := NewClient(WithTimeout(50 * time.Millisecond))
+	start := time.Now()
+
+	_, err := client.Send(context.Background(), SlackRequest{
+		Text:    "Build failed!",
+		Webhook: testServer.URL,
+	})
+
+	assert.NotNil(test, err)
+	assert.Less(test, time.Since(start), 400*time.Millisecond)
+}
+
+func TestClientCancellation(test *testing.T) {
+	requests := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests++
+		writer.WriteHeader(503)
+	}))
+	defer testServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+
+	_, err := NewClient().Send(ctx, SlackRequest{
+		Text:    "Build failed!",
+		Webhook: testServer.URL,
+		Retry:   RetryPolicy{MaxRetries: 5, InitialBackoff: 10 * time.Second},
+	})
+
+	// Retries should be abandoned once the context is cancelled
+	assert.Equal(test, "HTTP request to Slack failed", err.Error())
+	assert.Equal(test, 1, requests)
+	assert.Less(test, time.Since(start), 5*time.Second)
+}
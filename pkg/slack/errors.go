@@ -0,0 +1,45 @@
+package slack
+
+import (
+	"net/http"
+
+	"github.com/devatherock/simple-slack/internal/delivery"
+)
+
+// Name of the backend in delivery errors
+const slackProvider string = "Slack"
+
+// Failed delivery of a message to Slack or another backend. Use errors.As
+// to inspect it. Same as notifier.DeliveryError
+type DeliveryError = delivery.DeliveryError
+
+// Creates an error for a request to Slack that didn't get a response, like
+// a connection failure or a timeout
+func newNetworkError(err error) *DeliveryError {
+	return delivery.NetworkError(slackProvider, err)
+}
+
+// Creates an error from a failed HTTP response of Slack
+func newResponseError(res *http.Response) *DeliveryError {
+	return delivery.ResponseError(slackProvider, res)
+}
+
+// Same as notifier.NetworkError, kept for the other notification backends
+func NetworkError(provider string, err error) *DeliveryError {
+	return delivery.NetworkError(provider, err)
+}
+
+// Same as notifier.ResponseError, kept for the other notification backends
+func ResponseError(provider string, res *http.Response) *DeliveryError {
+	return delivery.ResponseError(provider, res)
+}
+
+// Creates an error from a failed Web API call
+func newApiError(reason string) *DeliveryError {
+	return &DeliveryError{
+		Provider:   slackProvider,
+		StatusCode: http.StatusOK,
+		Reason:     reason,
+		Retryable:  contains(retryableApiErrors, reason),
+	}
+}
@@ -0,0 +1,142 @@
+//go:build test
+// +build test
+
+package slack
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryErrorFromWebhook(test *testing.T) {
+	cases := []struct {
+		statusCode int
+		body       string
+		retryAfter string
+		expected   DeliveryError
+		message    string
+	}{
+		{
+			400,
+			"invalid_payload",
+			"",
+			DeliveryError{Provider: "Slack", StatusCode: 400, Reason: "invalid_payload"},
+			"HTTP request to Slack failed: invalid_payload",
+		},
+		{
+			410,
+			"channel_is_archived\n",
+			"",
+			DeliveryError{Provider: "Slack", StatusCode: 410, Reason: "channel_is_archived"},
+			"HTTP request to Slack failed: channel_is_archived",
+		},
+		{
+			429,
+			"rate_limited",
+			"30",
+			DeliveryError{Provider: "Slack", StatusCode: 429, Reason: "rate_limited", Retryable: true, RetryAfter: 30 * time.Second},
+			"HTTP request to Slack failed: rate_limited",
+		},
+		{
+			500,
+			"",
+			"",
+			DeliveryError{Provider: "Slack", StatusCode: 500, Retryable: true},
+			"HTTP request to Slack failed",
+		},
+	}
+
+	for _, data := range cases {
+		testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if data.retryAfter != "" {
+				writer.Header().Set("Retry-After", data.retryAfter)
+			}
+			writer.WriteHeader(data.statusCode)
+			fmt.Fprint(writer, data.body)
+		}))
+
+		err := Notify(SlackRequest{
+			Text:    "Build failed!",
+			Webhook: testServer.URL,
+		})
+		testServer.Close()
+
+		var deliveryError *DeliveryError
+		assert.True(test, errors.As(err, &deliveryError))
+		assert.Equal(test, data.expected, *deliveryError)
+		assert.Equal(test, data.message, err.Error())
+	}
+}
+
+func TestDeliveryErrorFromWebApi(test *testing.T) {
+	cases := []struct {
+		statusCode int
+		body       string
+		expected   DeliveryError
+		message    string
+	}{
+		{
+			200,
+			`{"ok":false,"error":"no_text"}`,
+			DeliveryError{Provider: "Slack", StatusCode: 200, Reason: "no_text"},
+			"Slack API call failed: no_text",
+		},
+		{
+			200,
+			`{"ok":false,"error":"service_unavailable"}`,
+			DeliveryError{Provider: "Slack", StatusCode: 200, Reason: "service_unavailable", Retryable: true},
+			"Slack API call failed: service_unavailable",
+		},
+		{
+			429,
+			`{"ok":false,"error":"ratelimited"}`,
+			DeliveryError{Provider: "Slack", StatusCode: 429, Reason: "ratelimited", Retryable: true},
+			"HTTP request to Slack failed: ratelimited",
+		},
+	}
+
+	for _, data := range cases {
+		testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set("Content-Type", "application/json")
+			writer.WriteHeader(data.statusCode)
+			fmt.Fprint(writer, data.body)
+		}))
+		helper.SetEnvironmentVariable(test, "SLACK_API_HOST", testServer.URL)
+
+		err := Notify(SlackRequest{
+			Text:    "Build failed!",
+			Channel: "general",
+			Token:   "xoxb-secret",
+		})
+		testServer.Close()
+
+		var deliveryError *DeliveryError
+		assert.True(test, errors.As(err, &deliveryError))
+		assert.Equal(test, data.expected, *deliveryError)
+		assert.Equal(test, data.message, err.Error())
+	}
+}
+
+func TestDeliveryErrorFromNetwork(test *testing.T) {
+	err := Notify(SlackRequest{
+		Text:    "Build failed!",
+		Webhook: "http://localhost:1/services/T0/B0/X0",
+	})
+
+	var deliveryError *DeliveryError
+	assert.True(test, errors.As(err, &deliveryError))
+	assert.True(test, deliveryError.Retryable)
+	assert.Equal(test, 0, deliveryError.StatusCode)
+	assert.NotNil(test, errors.Unwrap(err))
+
+	// The path of the webhook holds its secret
+	assert.Contains(test, err.Error(), `Post "http://localhost:1": `)
+	assert.NotContains(test, err.Error(), "/services/T0/B0/X0")
+}
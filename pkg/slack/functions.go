@@ -0,0 +1,229 @@
+package slack
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/devatherock/simple-slack/internal/textutil"
+)
+
+// Length of the abbreviated commit SHAs shown by git
+const shortShaLength int = 7
+
+// Template helper function, documented through the functions command
+type HelperFunction struct {
+	Name        string
+	Usage       string // Arguments of the function, like url label
+	Description string
+	Example     string // Template using the function
+	Output      string // Rendered example
+	Func        interface{}
+}
+
+// Helper functions for Slack messages, available to all templates along
+// with the sprig functions
+var helperFunctions = []HelperFunction{
+	{
+		"slackLink", "url label", "Link to the URL with the label, which is escaped",
+		`{{slackLink "https://ci.example.com/builds/42" "Build #42"}}`,
+		"<https://ci.example.com/builds/42|Build #42>",
+		slackLink,
+	},
+	{
+		"mrkdwnEscape", "text", "Escapes &, < and >, so that the text isn't read as a link, mention or markup",
+		`{{mrkdwnEscape "Merge <feature> & fix"}}`,
+		"Merge &lt;feature&gt; &amp; fix",
+		mrkdwnEscape,
+	},
+	{
+		"mentionUser", "id", "Mentions the user with the ID",
+		`{{mentionUser "U024BE7LH"}}`,
+		"<@U024BE7LH>",
+		mentionUser,
+	},
+	{
+		"mentionGroup", "id", "Mentions the user group with the ID, or here, channel or everyone",
+		`{{mentionGroup "SAZ94GDB8"}} {{mentionGroup "here"}}`,
+		"<!subteam^SAZ94GDB8> <!here>",
+		mentionGroup,
+	},
+	{
+		"channelRef", "id", "Links to the channel with the ID",
+		`{{channelRef "C024BE7LR"}}`,
+		"<#C024BE7LR>",
+		channelRef,
+	},
+	{
+		"codeBlock", "text", "Wraps the text in a code block",
+		`{{codeBlock "go test ./..."}}`,
+		"```\ngo test ./...\n```",
+		codeBlock,
+	},
+	{
+		"truncate", "length text", "Shortens the text to the length, ending it with an ellipsis when cut",
+		`{{"Fixed the login page redirect" | truncate 12}}`,
+		"Fixed the l…",
+		truncate,
+	},
+	{
+		"duration", "start end", "Time between two Unix timestamps, or the given number of seconds. An empty end means now",
+		`{{duration "1700000000" "1700003725"}} {{duration 90}}`,
+		"1h 2m 5s 1m 30s",
+		duration,
+	},
+	{
+		"shortSha", "sha", "Abbreviates the commit SHA to 7 characters",
+		`{{shortSha "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d"}}`,
+		"7fd1a60",
+		shortSha,
+	},
+	{
+		"statusEmoji", "status", "Emoji of the build status",
+		`{{statusEmoji "success"}} {{statusEmoji "failure"}} {{statusEmoji "running"}}`,
+		":white_check_mark: :x: :hourglass_flowing_sand:",
+		statusEmoji,
+	},
+}
+
+// Lists the helper functions available to templates
+func HelperFunctions() []HelperFunction {
+	return helperFunctions
+}
+
+func helperFuncMap() template.FuncMap {
+	funcMap := template.FuncMap{}
+	for _, function := range helperFunctions {
+		funcMap[function.Name] = function.Func
+	}
+
+	return funcMap
+}
+
+func slackLink(url string, label string) string {
+	if label == "" {
+		return "<" + url + ">"
+	}
+
+	return "<" + url + "|" + mrkdwnEscape(label) + ">"
+}
+
+func mrkdwnEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+func mentionUser(id string) string {
+	return "<@" + id + ">"
+}
+
+func mentionGroup(id string) string {
+	switch id {
+	case "here", "channel", "everyone":
+		return "<!" + id + ">"
+	default:
+		return "<!subteam^" + id + ">"
+	}
+}
+
+func channelRef(id string) string {
+	return "<#" + id + ">"
+}
+
+func codeBlock(text string) string {
+	return "```\n" + text + "\n```"
+}
+
+// Takes the length first, so that the text can be piped in
+func truncate(length int, text string) string {
+	return textutil.Truncate(text, length)
+}
+
+// Formats the time between the start and end timestamps like 1h 2m 5s.
+// With a single argument, formats the number of seconds instead
+func duration(values ...interface{}) (string, error) {
+	if len(values) == 0 || len(values) > 2 {
+		return "", errors.New("duration takes a number of seconds, or start and end timestamps")
+	}
+
+	start, err := toSeconds(values[0])
+	if err != nil {
+		return "", err
+	}
+	if len(values) == 1 {
+		return formatDuration(time.Duration(start) * time.Second), nil
+	}
+
+	end, err := toSeconds(values[1])
+	if err != nil {
+		return "", err
+	}
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+
+	return formatDuration(time.Duration(end-start) * time.Second), nil
+}
+
+// Reads a number or a string holding a number, as CI systems provide the
+// timestamps as environment variables
+func toSeconds(value interface{}) (int64, error) {
+	text := strings.TrimSpace(fmt.Sprint(value))
+	if text == "" {
+		return 0, nil
+	}
+
+	seconds, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, errors.New("Invalid duration or timestamp " + text)
+	}
+
+	return int64(seconds), nil
+}
+
+func formatDuration(length time.Duration) string {
+	if length < time.Second {
+		return "0s"
+	}
+
+	parts := []string{}
+	hours := int64(length / time.Hour)
+	minutes := int64(length%time.Hour) / int64(time.Minute)
+	seconds := int64(length%time.Minute) / int64(time.Second)
+	if hours > 0 {
+		parts = append(parts, strconv.FormatInt(hours, 10)+"h")
+	}
+	if minutes > 0 {
+		parts = append(parts, strconv.FormatInt(minutes, 10)+"m")
+	}
+	if seconds > 0 {
+		parts = append(parts, strconv.FormatInt(seconds, 10)+"s")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func shortSha(sha string) string {
+	if len(sha) <= shortShaLength {
+		return sha
+	}
+
+	return sha[:shortShaLength]
+}
+
+// Accepts the statuses of the supported CI systems, like passed or failed,
+// along with the normalized ones
+func statusEmoji(status string) string {
+	switch strings.ToLower(status) {
+	case "success", "passed", "fixed":
+		return ":white_check_mark:"
+	case "failure", "failed", "failing", "error", "errored", "killed":
+		return ":x:"
+	case "running", "pending", "started":
+		return ":hourglass_flowing_sand:"
+	default:
+		return ":grey_question:"
+	}
+}
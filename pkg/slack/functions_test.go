@@ -0,0 +1,66 @@
+//go:build test
+// +build test
+
+package slack
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHelperFunctionExamples(test *testing.T) {
+	for _, function := range HelperFunctions() {
+		actual, err := parseTemplate(context.Background(), function.Example)
+
+		assert.Nil(test, err, function.Name)
+		assert.Equal(test, function.Output, actual, function.Name)
+	}
+}
+
+func TestHelperFunctions(test *testing.T) {
+	cases := []struct{ template, expected string }{
+		{`{{slackLink "https://example.com" ""}}`, "<https://example.com>"},
+		{`{{slackLink "https://example.com" "a <b> & c"}}`, "<https://example.com|a &lt;b&gt; &amp; c>"},
+		{`{{mentionGroup "channel"}} {{mentionGroup "everyone"}}`, "<!channel> <!everyone>"},
+		{`{{truncate 5 "Short"}}|{{truncate 4 "Lösung"}}|{{truncate 0 "Text"}}`, "Short|Lös…|"},
+		{`{{duration "1700000000" "1700000000"}}`, "0s"},
+		{`{{duration "1700003600" "1700000000"}}`, "0s"},
+		{`{{duration 1700000000 1700007200}}`, "2h"},
+		{`{{shortSha "abc"}}`, "abc"},
+		{`{{statusEmoji "Failed"}} {{statusEmoji "passed"}} {{statusEmoji ""}}`, ":x: :white_check_mark: :grey_question:"},
+	}
+
+	for _, data := range cases {
+		actual, err := parseTemplate(context.Background(), data.template)
+
+		assert.Nil(test, err, data.template)
+		assert.Equal(test, data.expected, actual)
+	}
+}
+
+func TestDurationUntilNow(test *testing.T) {
+	started := strconv.FormatInt(time.Now().Add(-270*time.Second).Unix(), 10)
+	actual, err := parseTemplate(context.Background(), `{{duration "`+started+`" ""}}`)
+
+	assert.Nil(test, err)
+	assert.Regexp(test, `^4m 3[01]s$`, actual)
+}
+
+func TestDurationErrors(test *testing.T) {
+	cases := []string{
+		`{{duration}}`,
+		`{{duration "yesterday"}}`,
+		`{{duration "1700000000" "today"}}`,
+		`{{duration 1 2 3}}`,
+	}
+
+	for _, data := range cases {
+		_, err := parseTemplate(context.Background(), data)
+
+		assert.NotNil(test, err, data)
+	}
+}
@@ -0,0 +1,128 @@
+package slack
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Name of the template holding the message text
+const messageTemplate string = "message"
+
+// Extension of the files loaded into the template library
+const templateExtension string = ".tmpl"
+
+// Template used when the library has no template named after the build
+// status
+const defaultTemplate string = "default"
+
+var (
+	templateLibraryMutex sync.RWMutex
+	templateLibrary      *template.Template
+)
+
+// Loads the *.tmpl files in the directory as named templates, that every
+// template can use through {{template "name" .}} or {{include "name" .}}.
+// Each file is named after the file without the extension, along with the
+// templates it defines. An empty directory unloads the templates
+func LoadTemplates(dir string) error {
+	if dir == "" {
+		setTemplateLibrary(nil)
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+templateExtension))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New("No " + templateExtension + " files found in " + dir)
+	}
+
+	library := template.New("")
+	library.Funcs(templateFuncs(library))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), templateExtension)
+		_, err = library.New(name).Parse(string(data))
+		if err != nil {
+			return err
+		}
+	}
+
+	setTemplateLibrary(library)
+	return nil
+}
+
+func setTemplateLibrary(library *template.Template) {
+	templateLibraryMutex.Lock()
+	defer templateLibraryMutex.Unlock()
+
+	templateLibrary = library
+}
+
+// Text of a message that renders the library template named after the
+// build status, like success or failure, or the default template
+// otherwise. Empty when the library has neither
+func StatusTemplate(status string) string {
+	templateLibraryMutex.RLock()
+	defer templateLibraryMutex.RUnlock()
+
+	if templateLibrary == nil {
+		return ""
+	}
+
+	for _, name := range []string{status, defaultTemplate} {
+		if name != "" && templateLibrary.Lookup(name) != nil {
+			return `{{template "` + name + `" .}}`
+		}
+	}
+
+	return ""
+}
+
+// Creates the template to parse a message into, with the library templates
+// and the template functions
+func newTemplate() (root *template.Template, err error) {
+	templateLibraryMutex.RLock()
+	library := templateLibrary
+	templateLibraryMutex.RUnlock()
+
+	if library == nil {
+		root = template.New(messageTemplate)
+	} else {
+		root, err = library.Clone()
+		if err != nil {
+			return
+		}
+		root = root.New(messageTemplate)
+	}
+
+	root.Funcs(templateFuncs(root))
+	return
+}
+
+// Template functions, with include bound to the templates associated with
+// the root
+func templateFuncs(root *template.Template) template.FuncMap {
+	funcMap := templateFuncMap()
+
+	// Unlike the template action, renders a template into a string that
+	// can be piped, and takes a name that isn't a constant, like
+	// {{include .Build.Status .}}
+	funcMap["include"] = func(name string, data interface{}) (string, error) {
+		buffer := new(bytes.Buffer)
+		err := root.ExecuteTemplate(buffer, name, data)
+		return buffer.String(), err
+	}
+
+	return funcMap
+}
@@ -0,0 +1,103 @@
+//go:build test
+// +build test
+
+package slack
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Writes the templates into a directory and loads them for the duration of
+// the test
+func loadTemplates(test *testing.T, templates map[string]string) string {
+	dir := test.TempDir()
+	for name, text := range templates {
+		assert.Nil(test, os.WriteFile(filepath.Join(dir, name), []byte(text), 0644))
+	}
+
+	test.Cleanup(func() { LoadTemplates("") })
+	assert.Nil(test, LoadTemplates(dir))
+
+	return dir
+}
+
+func TestParseTemplateWithLibrary(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_NUMBER", "42")
+	loadTemplates(test, map[string]string{
+		"footer.tmpl":  `{{.Repo.Name}}#{{.Build.Number}}`,
+		"success.tmpl": `Passed: {{template "footer" .}}`,
+		"shared.tmpl":  `{{define "emoji"}}:white_check_mark:{{end}}{{define "failure"}}Failed: {{template "footer" .}}{{end}}`,
+		"notes.txt":    `{{define "notes"}}Not loaded{{end}}`,
+	})
+
+	cases := []struct{ template, expected string }{
+		{`{{template "footer" .}}`, "octocat/hello-world#42"},
+		{`{{template "emoji"}} {{template "success" .}}`, ":white_check_mark: Passed: octocat/hello-world#42"},
+		{`{{include "footer" . | upper}}`, "OCTOCAT/HELLO-WORLD#42"},
+		{`{{include (print "fail" "ure") .}}`, "Failed: octocat/hello-world#42"},
+		{`Build {{.Build.Number}}`, "Build 42"},
+	}
+
+	for _, data := range cases {
+		actual, err := parseTemplate(context.Background(), data.template)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual)
+	}
+
+	_, err := parseTemplate(context.Background(), `{{template "notes"}}`)
+	assert.NotNil(test, err)
+}
+
+func TestParseTemplateWithoutLibrary(test *testing.T) {
+	actual, err := parseTemplate(context.Background(), `{{include "footer" .}}`)
+
+	assert.NotNil(test, err)
+	assert.Equal(test, "", actual)
+}
+
+func TestLoadTemplatesErrors(test *testing.T) {
+	test.Cleanup(func() { LoadTemplates("") })
+
+	err := LoadTemplates(test.TempDir())
+	assert.NotNil(test, err)
+	assert.Contains(test, err.Error(), "No .tmpl files found in ")
+
+	dir := test.TempDir()
+	assert.Nil(test, os.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte(`{{.Build.Number`), 0644))
+	err = LoadTemplates(dir)
+	assert.NotNil(test, err)
+	assert.Contains(test, err.Error(), "broken")
+}
+
+func TestStatusTemplate(test *testing.T) {
+	assert.Equal(test, "", StatusTemplate(StatusSuccess))
+
+	loadTemplates(test, map[string]string{
+		"success.tmpl": `Passed`,
+		"default.tmpl": `Completed`,
+	})
+
+	cases := []struct{ status, expected string }{
+		{StatusSuccess, `{{template "success" .}}`},
+		{StatusFailure, `{{template "default" .}}`},
+		{"", `{{template "default" .}}`},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, StatusTemplate(data.status))
+	}
+
+	loadTemplates(test, map[string]string{
+		"footer.tmpl": `Footer`,
+	})
+	assert.Equal(test, "", StatusTemplate(StatusFailure))
+}
@@ -0,0 +1,176 @@
+package slack
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Replaces the secrets within rendered messages and logs
+const redactedText string = "****"
+
+// Shorter values, like 1 or true, would mask unrelated text
+const minSecretLength int = 4
+
+var defaultRedactor = NewRedactor()
+
+// Replaces known secret values and values matching secret patterns with
+// ****
+type Redactor struct {
+	mutex    sync.RWMutex
+	secrets  map[string]bool
+	patterns []*regexp.Regexp
+	replacer *strings.Replacer
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{secrets: make(map[string]bool)}
+}
+
+// Adds secret values to redact. Empty and very short values are ignored
+func (redactor *Redactor) AddSecrets(values ...string) {
+	redactor.mutex.Lock()
+	defer redactor.mutex.Unlock()
+
+	added := false
+	for _, value := range values {
+		if len(value) >= minSecretLength && !redactor.secrets[value] {
+			redactor.secrets[value] = true
+			added = true
+		}
+	}
+
+	if added {
+		// Longer secrets go first, so that a secret containing another one
+		// is redacted as a whole
+		secrets := make([]string, 0, len(redactor.secrets))
+		for secret := range redactor.secrets {
+			secrets = append(secrets, secret)
+		}
+		sort.Slice(secrets, func(first, second int) bool {
+			return len(secrets[first]) > len(secrets[second])
+		})
+
+		replacements := make([]string, 0, 2*len(secrets))
+		for _, secret := range secrets {
+			replacements = append(replacements, secret, redactedText)
+		}
+		redactor.replacer = strings.NewReplacer(replacements...)
+	}
+}
+
+// Adds regular expressions matching secrets to redact
+func (redactor *Redactor) AddPatterns(patterns ...string) error {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		expression, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, expression)
+	}
+
+	redactor.mutex.Lock()
+	defer redactor.mutex.Unlock()
+	redactor.patterns = append(redactor.patterns, compiled...)
+
+	return nil
+}
+
+func (redactor *Redactor) Redact(text string) string {
+	redactor.mutex.RLock()
+	defer redactor.mutex.RUnlock()
+
+	if redactor.replacer != nil {
+		text = redactor.replacer.Replace(text)
+	}
+	for _, pattern := range redactor.patterns {
+		text = pattern.ReplaceAllString(text, redactedText)
+	}
+
+	return text
+}
+
+// Adds secret values to the redactor used for all messages and logs
+func AddSecrets(values ...string) {
+	defaultRedactor.AddSecrets(values...)
+}
+
+// Adds regular expressions matching secrets to the redactor used for all
+// messages and logs
+func AddSecretPatterns(patterns ...string) error {
+	return defaultRedactor.AddPatterns(patterns...)
+}
+
+// Adds the values of the environment variables that hold secrets by the
+// conventions of Drone and Vela, like SLACK_WEBHOOK or PLUGIN_TOKEN, along
+// with the variables in the denylist
+func AddSecretsFromEnv() {
+	for _, element := range os.Environ() {
+		name, value, _ := strings.Cut(element, "=")
+		if IsSecretEnv(name) {
+			AddSecrets(value)
+		}
+	}
+}
+
+// Replaces the known secrets within the text
+func Redact(text string) string {
+	return defaultRedactor.Redact(text)
+}
+
+// Key of the redactors carried by a context
+type redactorsKey struct{}
+
+// Returns a context that also redacts the secrets, for secrets that belong
+// to a single request, like the webhook of an API request. Messages
+// rendered and entries logged with the context are redacted
+func WithSecrets(ctx context.Context, secrets ...string) context.Context {
+	redactor := NewRedactor()
+	redactor.AddSecrets(secrets...)
+
+	parents := contextRedactors(ctx)
+	redactors := append(parents[:len(parents):len(parents)], redactor)
+
+	return context.WithValue(ctx, redactorsKey{}, redactors)
+}
+
+func contextRedactors(ctx context.Context) []*Redactor {
+	if ctx == nil {
+		return nil
+	}
+
+	redactors, _ := ctx.Value(redactorsKey{}).([]*Redactor)
+	return redactors
+}
+
+// Replaces the known secrets and the secrets of the context within the text
+func RedactContext(ctx context.Context, text string) string {
+	text = Redact(text)
+	for _, redactor := range contextRedactors(ctx) {
+		text = redactor.Redact(text)
+	}
+
+	return text
+}
+
+// Log formatter that redacts the known secrets from the formatted entries,
+// like webhook URLs within errors. The secrets of the context of an entry
+// are redacted as well
+type RedactingFormatter struct {
+	Formatter log.Formatter
+}
+
+func (formatter *RedactingFormatter) Format(entry *log.Entry) ([]byte, error) {
+	data, err := formatter.Formatter.Format(entry)
+	if err != nil {
+		return data, err
+	}
+
+	return []byte(RedactContext(entry.Context, string(data))), nil
+}
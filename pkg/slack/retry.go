@@ -0,0 +1,27 @@
+package slack
+
+import (
+	"context"
+
+	"github.com/devatherock/simple-slack/internal/delivery"
+	log "github.com/sirupsen/logrus"
+)
+
+// Web API error codes that indicate a transient failure on Slack's side.
+// Presorted for contains check to work
+var retryableApiErrors = []string{"fatal_error", "internal_error", "ratelimited", "request_timeout", "service_unavailable"}
+
+// Decides how failed deliveries are retried. The zero value disables
+// retries. Same as notifier.RetryPolicy
+type RetryPolicy = delivery.RetryPolicy
+
+// Calls the send function until it succeeds, fails with a permanent error,
+// the retries are exhausted or the context is cancelled
+func (client *Client) sendWithRetry(ctx context.Context, policy RetryPolicy, send func() error) error {
+	return Retry(ctx, policy, client.logger, send)
+}
+
+// Same as notifier.Retry, kept for the other notification backends
+func Retry(ctx context.Context, policy RetryPolicy, logger log.FieldLogger, send func() error) error {
+	return delivery.Retry(ctx, policy, logger, send)
+}
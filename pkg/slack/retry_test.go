@@ -0,0 +1,135 @@
+//go:build test
+// +build test
+
+package slack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devatherock/simple-slack/internal/delivery"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Records the delays instead of sleeping
+func captureSleeps(test *testing.T) *[]time.Duration {
+	delays := []time.Duration{}
+	originalSleep := delivery.Sleep
+	delivery.Sleep = func(ctx context.Context, delay time.Duration) error {
+		delays = append(delays, delay)
+		return nil
+	}
+
+	test.Cleanup(func() {
+		delivery.Sleep = originalSleep
+	})
+
+	return &delays
+}
+
+func TestPostRetriesWebhook(test *testing.T) {
+	cases := []struct {
+		statusCodes      []int
+		retryAfter       string
+		expectedRequests int
+		expectedError    string
+	}{
+		{[]int{500, 503, 200}, "", 3, ""},
+		{[]int{429, 200}, "7", 2, ""},
+		{[]int{500, 500, 500, 500}, "", 4, "HTTP request to Slack failed"},
+		{[]int{400, 200}, "", 1, "HTTP request to Slack failed"},
+		{[]int{404, 200}, "", 1, "HTTP request to Slack failed"},
+	}
+
+	for _, data := range cases {
+		delays := captureSleeps(test)
+		requestIndex := 0
+		testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if data.retryAfter != "" {
+				writer.Header().Set("Retry-After", data.retryAfter)
+			}
+			writer.WriteHeader(data.statusCodes[requestIndex])
+			requestIndex++
+		}))
+
+		err := Notify(SlackRequest{
+			Text:    "Build failed!",
+			Webhook: testServer.URL,
+			Retry: RetryPolicy{
+				MaxRetries:     3,
+				InitialBackoff: time.Second,
+				MaxBackoff:     10 * time.Second,
+			},
+		})
+		testServer.Close()
+
+		assert.Equal(test, data.expectedRequests, requestIndex)
+		assert.Equal(test, data.expectedRequests-1, len(*delays))
+		if data.expectedError == "" {
+			assert.Nil(test, err)
+		} else {
+			assert.Equal(test, data.expectedError, err.Error())
+		}
+
+		if data.retryAfter != "" {
+			assert.Equal(test, []time.Duration{7 * time.Second}, *delays)
+		}
+	}
+}
+
+func TestPostRetriesWebApi(test *testing.T) {
+	cases := []struct {
+		responses        []string
+		expectedRequests int
+		expectedError    string
+	}{
+		{[]string{`{"ok":false,"error":"ratelimited"}`, `{"ok":true,"channel":"C1234","ts":"1"}`}, 2, ""},
+		{[]string{`{"ok":false,"error":"internal_error"}`, `{"ok":true,"channel":"C1234","ts":"1"}`}, 2, ""},
+		{[]string{`{"ok":false,"error":"channel_not_found"}`, `{"ok":true,"channel":"C1234","ts":"1"}`}, 1, "Slack API call failed: channel_not_found"},
+		{[]string{`{"ok":false,"error":"invalid_blocks"}`, `{"ok":true,"channel":"C1234","ts":"1"}`}, 1, "Slack API call failed: invalid_blocks"},
+	}
+
+	for _, data := range cases {
+		captureSleeps(test)
+		requestIndex := 0
+		testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set("Content-Type", "application/json")
+			fmt.Fprintln(writer, data.responses[requestIndex])
+			requestIndex++
+		}))
+		helper.SetEnvironmentVariable(test, "SLACK_API_HOST", testServer.URL)
+
+		err := Notify(SlackRequest{
+			Text:    "Build failed!",
+			Channel: "general",
+			Token:   "xoxb-secret",
+			Retry:   RetryPolicy{MaxRetries: 2, InitialBackoff: time.Second},
+		})
+		testServer.Close()
+
+		assert.Equal(test, data.expectedRequests, requestIndex)
+		if data.expectedError == "" {
+			assert.Nil(test, err)
+		} else {
+			assert.Equal(test, data.expectedError, err.Error())
+		}
+	}
+}
+
+func TestPostRetriesNetworkError(test *testing.T) {
+	delays := captureSleeps(test)
+
+	err := Notify(SlackRequest{
+		Text:    "Build failed!",
+		Webhook: "http://localhost:1",
+		Retry:   RetryPolicy{MaxRetries: 2, InitialBackoff: time.Second},
+	})
+
+	assert.NotNil(test, err)
+	assert.Equal(test, 2, len(*delays))
+}
@@ -0,0 +1,78 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+const rocketChatProvider string = "Rocket.Chat"
+
+// Response of the Rocket.Chat chat.postMessage method
+type rocketChatResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Message struct {
+		Id     string `json:"_id"`
+		RoomId string `json:"rid"`
+	} `json:"message"`
+}
+
+// Posts the payload using the Rocket.Chat REST API, which unlike the webhook
+// supports threads. The ID of the posted message is returned as its
+// timestamp, so that it can be the parent of a thread
+func (client *Client) postToRocketChat(ctx context.Context, request SlackRequest, payload map[string]interface{}) (response SlackResponse, err error) {
+	if threadTs, ok := payload["thread_ts"]; ok {
+		delete(payload, "thread_ts")
+		payload["tmid"] = threadTs
+	}
+
+	if replyBroadcast, ok := payload["reply_broadcast"]; ok {
+		delete(payload, "reply_broadcast")
+		payload["tshow"] = replyBroadcast
+	}
+
+	data, _ := json.Marshal(payload)
+	req, err := client.newRequest(ctx, strings.TrimSuffix(request.RocketChatUrl, "/")+"/api/v1/chat.postMessage", data)
+	if err != nil {
+		return
+	}
+	req.Header.Add("X-User-Id", request.RocketChatUserId)
+	req.Header.Add("X-Auth-Token", request.RocketChatToken)
+
+	res, err := client.httpClient.Do(req)
+	if err != nil {
+		err = NetworkError(rocketChatProvider, err)
+		return
+	}
+	defer res.Body.Close()
+	client.logger.Info("Called Rocket.Chat method chat.postMessage with http status ", res.StatusCode)
+
+	if res.StatusCode > 399 {
+		err = ResponseError(rocketChatProvider, res)
+		return
+	}
+
+	responseBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return
+	}
+
+	apiResponse := rocketChatResponse{}
+	err = json.Unmarshal(responseBody, &apiResponse)
+	if err != nil {
+		return
+	}
+
+	if !apiResponse.Success {
+		deliveryError := newApiError(apiResponse.Error)
+		deliveryError.Provider = rocketChatProvider
+		err = deliveryError
+		return
+	}
+
+	response.Channel = apiResponse.Message.RoomId
+	response.Timestamp = apiResponse.Message.Id
+	return
+}
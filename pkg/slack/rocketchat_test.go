@@ -0,0 +1,102 @@
+//go:build test
+// +build test
+
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostToRocketChat(test *testing.T) {
+	// Test HTTP server
+	var capturedRequests []map[string]interface{}
+	var capturedPath, capturedUserId, capturedToken string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedPath = request.URL.Path
+		capturedUserId = request.Header.Get("X-User-Id")
+		capturedToken = request.Header.Get("X-Auth-Token")
+
+		data, _ := io.ReadAll(request.Body)
+		jsonRequest := make(map[string]interface{})
+		json.Unmarshal(data, &jsonRequest)
+		capturedRequests = append(capturedRequests, jsonRequest)
+
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(writer, `{"success":true,"channel":"general","message":{"_id":"msg%d","rid":"GENERAL"}}`, len(capturedRequests))
+	}))
+	defer testServer.Close()
+
+	request := SlackRequest{
+		Text:             "Build failed!",
+		Channel:          "#general",
+		Alias:            "CI",
+		Emoji:            ":rocket:",
+		RocketChatUrl:    testServer.URL + "/",
+		RocketChatUserId: "user1",
+		RocketChatToken:  "secret-token",
+	}
+	store := NewMemoryThreadStore()
+
+	parent, err := PostInThread(request, "build-42", store)
+	assert.Nil(test, err)
+	assert.Equal(test, SlackResponse{Channel: "GENERAL", Timestamp: "msg1"}, parent)
+
+	request.ReplyBroadcast = true
+	reply, err := PostInThread(request, "build-42", store)
+	assert.Nil(test, err)
+	assert.Equal(test, SlackResponse{Channel: "GENERAL", Timestamp: "msg2"}, reply)
+
+	assert.Equal(test, "/api/v1/chat.postMessage", capturedPath)
+	assert.Equal(test, "user1", capturedUserId)
+	assert.Equal(test, "secret-token", capturedToken)
+
+	assert.Equal(test, "CI", capturedRequests[0]["alias"])
+	assert.Equal(test, ":rocket:", capturedRequests[0]["emoji"])
+	assert.Equal(test, "#general", capturedRequests[0]["channel"])
+	assert.Nil(test, capturedRequests[0]["tmid"])
+
+	assert.Equal(test, "msg1", capturedRequests[1]["tmid"])
+	assert.Equal(test, true, capturedRequests[1]["tshow"])
+	assert.Nil(test, capturedRequests[1]["thread_ts"])
+	assert.Nil(test, capturedRequests[1]["reply_broadcast"])
+}
+
+func TestPostToRocketChatFailed(test *testing.T) {
+	cases := []struct {
+		statusCode int
+		body       string
+		expected   string
+	}{
+		{400, `{"success":false,"error":"error-invalid-channel"}`, "HTTP request to Rocket.Chat failed: error-invalid-channel"},
+		{200, `{"success":false,"error":"error-not-allowed"}`, "Rocket.Chat API call failed: error-not-allowed"},
+	}
+
+	for _, data := range cases {
+		testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set("Content-Type", "application/json")
+			writer.WriteHeader(data.statusCode)
+			fmt.Fprintln(writer, data.body)
+		}))
+
+		_, err := Post(SlackRequest{
+			Text:             "Build failed!",
+			Channel:          "#general",
+			RocketChatUrl:    testServer.URL,
+			RocketChatUserId: "user1",
+			RocketChatToken:  "secret-token",
+		})
+		testServer.Close()
+
+		var deliveryError *DeliveryError
+		assert.ErrorAs(test, err, &deliveryError)
+		assert.Equal(test, "Rocket.Chat", deliveryError.Provider)
+		assert.Equal(test, data.expected, err.Error())
+	}
+}
@@ -0,0 +1,108 @@
+package slack
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+)
+
+// Globs of the environment variables that commonly hold secrets. Hidden
+// from templates along with secretEnvVariables
+var defaultEnvDenylist = []string{"*_PASSWORD", "*_SECRET", "*_TOKEN", "*_WEBHOOK"}
+
+var (
+	envFilterMutex sync.RWMutex
+	envFilter      EnvFilter
+)
+
+// Decides which environment variables templates can read, through the
+// context and through the env and expandenv functions. Entries are exact
+// names or globs like *_TOKEN
+type EnvFilter struct {
+	Allowlist []string // When specified, only the matching variables are available
+	Denylist  []string // Matching variables are hidden, even when allowed
+}
+
+// Sets the filter applied to the environment variables of all templates,
+// in addition to the default denylist
+func SetEnvFilter(filter EnvFilter) {
+	envFilterMutex.Lock()
+	defer envFilterMutex.Unlock()
+
+	envFilter = filter
+}
+
+// Forms a filter from comma separated lists of names and globs
+func ParseEnvFilter(allowlist string, denylist string) EnvFilter {
+	return EnvFilter{Allowlist: splitList(allowlist), Denylist: splitList(denylist)}
+}
+
+func splitList(list string) (entries []string) {
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return
+}
+
+// Checks whether templates can read the environment variable
+func EnvAllowed(name string) bool {
+	if IsSecretEnv(name) {
+		return false
+	}
+
+	envFilterMutex.RLock()
+	defer envFilterMutex.RUnlock()
+
+	return len(envFilter.Allowlist) == 0 || matchesAny(envFilter.Allowlist, name)
+}
+
+// Checks whether the environment variable holds a secret, going by its
+// name and the denylist
+func IsSecretEnv(name string) bool {
+	envFilterMutex.RLock()
+	defer envFilterMutex.RUnlock()
+
+	return contains(secretEnvVariables, name) || matchesAny(defaultEnvDenylist, name) || matchesAny(envFilter.Denylist, name)
+}
+
+// Checks the name against exact names and globs
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched || pattern == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reads the environment variable if templates are allowed to read it
+func getAllowedEnv(name string) string {
+	if !EnvAllowed(name) {
+		return ""
+	}
+
+	return os.Getenv(name)
+}
+
+// Sprig and the helper functions, with env and expandenv restricted to the
+// allowed environment variables, so that templates can't read secrets
+func templateFuncMap() template.FuncMap {
+	funcMap := sprig.TxtFuncMap()
+	for name, function := range helperFuncMap() {
+		funcMap[name] = function
+	}
+	funcMap["env"] = getAllowedEnv
+	funcMap["expandenv"] = func(text string) string {
+		return os.Expand(text, getAllowedEnv)
+	}
+
+	return funcMap
+}
@@ -0,0 +1,90 @@
+//go:build test
+// +build test
+
+package slack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Sets the filter for the duration of the test
+func setEnvFilter(test *testing.T, filter EnvFilter) {
+	SetEnvFilter(filter)
+	test.Cleanup(func() { SetEnvFilter(EnvFilter{}) })
+}
+
+func TestParseTemplateSandboxedFunctions(test *testing.T) {
+	cases := []struct{ template, expected string }{
+		{`{{env "SLACK_WEBHOOK"}}`, ""},
+		{`{{env "PLUGIN_WEBHOOK"}}`, ""},
+		{`{{env "DEPLOY_TOKEN"}}`, ""},
+		{`{{env "NPM_PASSWORD"}}`, ""},
+		{`{{expandenv "Webhook: $SLACK_WEBHOOK, token: ${DEPLOY_TOKEN}"}}`, "Webhook: , token: "},
+		{`{{env "DRONE_REPO"}}`, "octocat/hello-world"},
+		{`{{expandenv "Repo: $DRONE_REPO"}}`, "Repo: octocat/hello-world"},
+		{`{{.DeployToken}}{{.NpmPassword}}`, "<no value><no value>"},
+	}
+
+	for _, data := range cases {
+		helper.SetEnvironmentVariable(test, "SLACK_WEBHOOK", "https://secreturl")
+		helper.SetEnvironmentVariable(test, "PLUGIN_WEBHOOK", "https://secreturl")
+		helper.SetEnvironmentVariable(test, "DEPLOY_TOKEN", "secret")
+		helper.SetEnvironmentVariable(test, "NPM_PASSWORD", "secret")
+		helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+		actual, err := parseTemplate(context.Background(), data.template)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual)
+	}
+}
+
+func TestParseTemplateWithEnvFilter(test *testing.T) {
+	cases := []struct {
+		filter   EnvFilter
+		template string
+		expected string
+	}{
+		{
+			ParseEnvFilter("", "AWS_*, RELEASE_NOTES"),
+			`{{.AwsRegion}}|{{env "AWS_REGION"}}|{{.ReleaseNotes}}|{{.DroneRepo}}`,
+			"<no value>||<no value>|octocat/hello-world",
+		},
+		{
+			ParseEnvFilter("DRONE_*,RELEASE_NOTES", "DRONE_COMMIT_*"),
+			`{{.AwsRegion}}|{{.ReleaseNotes}}|{{expandenv "$DRONE_REPO $DRONE_COMMIT_MESSAGE"}}|{{.Env.DroneRepo}}|{{.Repo.Name}}:{{.Commit.Message}}`,
+			"<no value>|Fixed bugs|octocat/hello-world |octocat/hello-world|octocat/hello-world:",
+		},
+		{
+			ParseEnvFilter("*", ""),
+			`{{env "SLACK_TOKEN"}}{{env "DEPLOY_TOKEN"}}`,
+			"",
+		},
+	}
+
+	for _, data := range cases {
+		helper.SetEnvironmentVariable(test, "DRONE", "true")
+		helper.SetEnvironmentVariable(test, "AWS_REGION", "us-east-1")
+		helper.SetEnvironmentVariable(test, "RELEASE_NOTES", "Fixed bugs")
+		helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+		helper.SetEnvironmentVariable(test, "DRONE_COMMIT_MESSAGE", "Updated readme")
+		helper.SetEnvironmentVariable(test, "SLACK_TOKEN", "secret")
+		helper.SetEnvironmentVariable(test, "DEPLOY_TOKEN", "secret")
+		setEnvFilter(test, data.filter)
+		actual, err := parseTemplate(context.Background(), data.template)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual)
+	}
+}
+
+func TestParseEnvFilter(test *testing.T) {
+	assert.Equal(test, EnvFilter{}, ParseEnvFilter("", " , "))
+	assert.Equal(test, EnvFilter{
+		Allowlist: []string{"DRONE_*", "CI"},
+		Denylist:  []string{"*_KEY"},
+	}, ParseEnvFilter("DRONE_*, CI", "*_KEY"))
+}
@@ -2,76 +2,95 @@ package slack
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"net/http"
 	"os"
 	"sort"
 	"strings"
-	"text/template"
 
-	"github.com/Masterminds/sprig"
-	log "github.com/sirupsen/logrus"
+	"github.com/devatherock/simple-slack/pkg/ci"
 )
 
 // Presorted for contains check to work
-var secretEnvVariables = []string{"PLUGIN_WEBHOOK", "SLACK_WEBHOOK", "WEBHOOK"}
+var secretEnvVariables = []string{"PLUGIN_ROCKETCHAT_TOKEN", "PLUGIN_SMTP_PASSWORD", "PLUGIN_TOKEN", "PLUGIN_WEBHOOK", "ROCKETCHAT_TOKEN", "SLACK_TOKEN", "SLACK_WEBHOOK", "SMTP_PASSWORD", "TOKEN", "WEBHOOK"}
 
 const defaultColor string = "#cfd3d7" // grey
 const successColor string = "#33ad7f" // green
 const failureColor string = "#a1040c" // red
 
+// Normalized build statuses
+const (
+	StatusSuccess string = "success"
+	StatusFailure string = "failure"
+	StatusRunning string = "running"
+)
+
 type SlackRequest struct {
-	Text    string `json:",omitempty"`
-	Channel string `json:",omitempty"`
-	Color   string `json:",omitempty"`
-	Title   string `json:",omitempty"`
-	Webhook string `json:",omitempty"`
-}
+	Text           string      `json:",omitempty"`
+	Channel        string      `json:",omitempty"`
+	Color          string      `json:",omitempty"`
+	Title          string      `json:",omitempty"`
+	Webhook        string      `json:",omitempty"`
+	Token          string      `json:",omitempty"` // Bot token. When specified, the message is posted using the Web API instead of the webhook
+	Timestamp      string      `json:",omitempty"` // Timestamp of a message to update in place. Needs a token and a channel ID
+	ThreadTs       string      `json:",omitempty"` // Timestamp of the parent message to reply to
+	ReplyBroadcast bool        `json:",omitempty"` // Also sends the thread reply to the channel
+	Retry          RetryPolicy `json:"-"`
+	Blocks         []Block     `json:",omitempty"`
+	BlocksTemplate string      `json:",omitempty"` // JSON or YAML document containing the blocks
+	ColorBar       bool        `json:",omitempty"` // Wraps the blocks in an attachment highlighted with the color
 
-func Notify(request SlackRequest) error {
-	err := Validate(request)
-	if err != nil {
-		return err
-	}
+	// Rocket.Chat specific options
+	Alias            string `json:",omitempty"` // Name the message is posted with
+	Avatar           string `json:",omitempty"` // URL of the avatar the message is posted with
+	Emoji            string `json:",omitempty"` // Emoji used as the avatar, like :rocket:
+	TitleLink        string `json:",omitempty"` // URL the title links to
+	Collapsed        bool   `json:",omitempty"` // Shows the attachment collapsed
+	ImageUrl         string `json:",omitempty"` // URL of an image shown within the attachment
+	RocketChatUrl    string `json:",omitempty"` // URL of the Rocket.Chat server. When specified, the message is posted using the REST API
+	RocketChatUserId string `json:",omitempty"`
+	RocketChatToken  string `json:",omitempty"` // Personal access token of the Rocket.Chat user
+}
 
-	payload, err := buildPayload(request)
-	if err != nil {
-		return err
-	}
+// Identifies a message posted using the Web API
+type SlackResponse struct {
+	Channel   string `json:"channel,omitempty"`
+	Timestamp string `json:"ts,omitempty"`
+}
 
-	data, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", request.Webhook, bytes.NewBuffer(data))
-	if err != nil {
-		return err
-	}
+// Posts the message to Slack
+func Notify(request SlackRequest) error {
+	_, err := Post(request)
+	return err
+}
 
-	req.Header.Add("Content-Type", "application/json")
-	client := &http.Client{}
+// Posts the message to Slack using the default client. The channel ID and
+// timestamp of the message are returned when using the Web API
+func Post(request SlackRequest) (SlackResponse, error) {
+	return defaultClient.Send(context.Background(), request)
+}
 
-	res, err := client.Do(req)
+// Builds the Slack HTTP request payload
+func buildPayload(ctx context.Context, request SlackRequest) (payload map[string]interface{}, err error) {
+	text, err := parseTemplate(ctx, request.Text)
 	if err != nil {
-		return err
+		return
 	}
-	defer res.Body.Close()
-	log.Info("Message posted to webhook with http status ", res.StatusCode)
 
-	if res.StatusCode > 399 {
-		return errors.New("HTTP request to Slack failed")
+	blocks := request.Blocks
+	if request.BlocksTemplate != "" {
+		blocks, err = parseBlocks(request.BlocksTemplate)
+		if err != nil {
+			return
+		}
 	}
 
-	return nil
-}
-
-// Builds the Slack HTTP request payload
-func buildPayload(request SlackRequest) (payload map[string]interface{}, err error) {
-	text, err := parseTemplate(request.Text)
-	if err != nil {
-		return
+	if len(blocks) > 0 {
+		return buildBlocksPayload(ctx, request, text, blocks)
 	}
 
 	// Build attachments section
-	attachments := [1]map[string]string{
+	attachments := [1]map[string]interface{}{
 		{
 			"color": getHighlightColor(request.Color),
 			"text":  text,
@@ -82,6 +101,18 @@ func buildPayload(request SlackRequest) (payload map[string]interface{}, err err
 		attachments[0]["title"] = request.Title
 	}
 
+	if request.TitleLink != "" {
+		attachments[0]["title_link"] = request.TitleLink
+	}
+
+	if request.Collapsed {
+		attachments[0]["collapsed"] = true
+	}
+
+	if request.ImageUrl != "" {
+		attachments[0]["image_url"] = request.ImageUrl
+	}
+
 	// Build complete payload
 	payload = map[string]interface{}{
 		"attachments": attachments,
@@ -91,66 +122,200 @@ func buildPayload(request SlackRequest) (payload map[string]interface{}, err err
 		payload["channel"] = request.Channel
 	}
 
+	addSenderOptions(request, payload)
+	return
+}
+
+// Adds the options that change how the sender is shown on Rocket.Chat
+func addSenderOptions(request SlackRequest, payload map[string]interface{}) {
+	if request.Alias != "" {
+		payload["alias"] = request.Alias
+	}
+
+	if request.Avatar != "" {
+		payload["avatar"] = request.Avatar
+	}
+
+	if request.Emoji != "" {
+		payload["emoji"] = request.Emoji
+	}
+}
+
+// Builds a Block Kit payload. The text is used as the notification fallback
+func buildBlocksPayload(ctx context.Context, request SlackRequest, text string, blocks []Block) (payload map[string]interface{}, err error) {
+	if request.Title != "" {
+		header := Block{Type: HeaderBlock, Text: &TextObject{Type: PlainText, Text: request.Title}}
+		blocks = append([]Block{header}, blocks...)
+	}
+
+	blocks, err = renderBlocks(ctx, blocks)
+	if err != nil {
+		return
+	}
+
+	if request.ColorBar {
+		attachment := map[string]interface{}{
+			"color":  getHighlightColor(request.Color),
+			"blocks": blocks,
+		}
+
+		if text != "" {
+			attachment["fallback"] = text
+		}
+
+		payload = map[string]interface{}{
+			"attachments": [1]map[string]interface{}{attachment},
+		}
+	} else {
+		payload = map[string]interface{}{
+			"blocks": blocks,
+		}
+
+		if text != "" {
+			payload["text"] = text
+		}
+	}
+
+	if request.Channel != "" {
+		payload["channel"] = request.Channel
+	}
+
+	addSenderOptions(request, payload)
 	return
 }
 
 // Validates the input parameters
 func Validate(request SlackRequest) error {
-	if request.Text == "" || request.Webhook == "" {
+	hasContent := request.Text != "" || len(request.Blocks) > 0 || request.BlocksTemplate != ""
+
+	if !hasContent || (request.Webhook == "" && request.Token == "" && request.RocketChatToken == "") {
 		return errors.New("Required parameters not specified")
 	}
 
+	// Web API needs to be told where to post the message
+	if request.Token != "" && request.Channel == "" {
+		return errors.New("Channel is required when using a token")
+	}
+
+	if request.RocketChatToken != "" && (request.RocketChatUrl == "" || request.RocketChatUserId == "" || request.Channel == "") {
+		return errors.New("Rocket.Chat URL, user ID and channel are required when using a Rocket.Chat token")
+	}
+
+	// Webhooks don't support updating messages
+	if request.Timestamp != "" && request.Token == "" {
+		return errors.New("Token is required to update a message")
+	}
+
 	return nil
 }
 
 // Decides the highlight color based on build status
-func getHighlightColor(inputColor string) (outputColor string) {
+func getHighlightColor(inputColor string) string {
 	if inputColor != "" {
-		outputColor = inputColor
-	} else if os.Getenv("DRONE") == "true" {
-		buildStatus := os.Getenv("DRONE_BUILD_STATUS")
-
-		switch buildStatus {
-		case "success":
-			outputColor = successColor
-		case "failure", "error", "killed":
-			outputColor = failureColor
-		default:
-			outputColor = defaultColor
-		}
-	} else if os.Getenv("VELA") == "true" {
-		buildStatus := os.Getenv("VELA_BUILD_STATUS")
-
-		switch buildStatus {
-		case "success", "running": // When none of the previous steps have failed, VELA_BUILD_STATUS has the value running within a step
-			outputColor = successColor
-		case "failure", "error":
-			outputColor = failureColor
-		default:
-			outputColor = defaultColor
-		}
-	} else {
-		outputColor = defaultColor
+		return inputColor
 	}
 
-	return
+	return StatusColor(BuildStatus())
+}
+
+// Exported form of getHighlightColor, for use by other notification backends
+func HighlightColor(inputColor string) string {
+	return getHighlightColor(inputColor)
+}
+
+// Maps a normalized build status to its highlight color
+func StatusColor(status string) string {
+	switch status {
+	case StatusSuccess:
+		return successColor
+	case StatusFailure:
+		return failureColor
+	default:
+		return defaultColor
+	}
+}
+
+// Reads the build status from the CI environment and normalizes it to
+// success or failure. Returns an empty string when the status is unknown
+func BuildStatus() string {
+	return ci.Current().Build.Status
+}
+
+// Exported form of parseTemplate. Same as notifier.ParseTemplate
+func ParseTemplate(ctx context.Context, templateText string) (string, error) {
+	return parseTemplate(ctx, templateText)
+}
+
+// Exported form of parseTemplate that adds the values to the context, for
+// backends that expose computed fields like the resolved status
+func ParseTemplateWith(ctx context.Context, templateText string, values map[string]interface{}) (string, error) {
+	text, err := ParseTemplateUnredacted(templateText, values)
+	return RedactContext(ctx, text), err
+}
+
+// Form of ParseTemplateWith that keeps the secrets, for the parts of a
+// request that aren't shown to anyone, like the URL and the headers
+func ParseTemplateUnredacted(templateText string, values map[string]interface{}) (string, error) {
+	templateContext := buildTemplateContext()
+	for name, value := range values {
+		templateContext[name] = value
+	}
+
+	return executeTemplate(templateText, templateContext)
 }
 
 // Processes the input text as a template with environment variables as the
-// context
-func parseTemplate(templateText string) (string, error) {
-	var templateContext = make(map[string]string)
+// context. Known secrets, including those of the context, are redacted from
+// the output
+func parseTemplate(ctx context.Context, templateText string) (string, error) {
+	text, err := executeTemplate(templateText, buildTemplateContext())
+	return RedactContext(ctx, text), err
+}
+
+// Builds the template context from the environment variables. The
+// variables are available as camel cased keys, both at the top level and
+// under Env, along with the CI neutral Build, Commit, Repo, Branch and Tag
+func buildTemplateContext() map[string]interface{} {
+	var templateContext = make(map[string]interface{})
+	var env = make(map[string]string)
 	for _, element := range os.Environ() {
 		variable := strings.Split(element, "=")
 
-		// Inject all environment variables other than secrets
-		if !contains(secretEnvVariables, variable[0]) {
+		// Inject all environment variables other than secrets and those
+		// hidden by the filter
+		if EnvAllowed(variable[0]) {
 			templateContext[envVariableToCamelCase(variable[0])] = variable[1]
+			env[envVariableToCamelCase(variable[0])] = variable[1]
+		}
+	}
+
+	// Variables like BRANCH keep their values, for backward compatibility
+	build := ci.CurrentWith(getAllowedEnv)
+	structured := map[string]interface{}{
+		"Env":    env,
+		"Build":  build.Build,
+		"Commit": build.Commit,
+		"Repo":   build.Repo,
+		"Branch": build.Branch,
+		"Tag":    build.Tag,
+	}
+	for name, value := range structured {
+		if _, ok := templateContext[name]; !ok {
+			templateContext[name] = value
 		}
 	}
 
+	return templateContext
+}
+
+func executeTemplate(templateText string, templateContext map[string]interface{}) (string, error) {
 	buffer := new(bytes.Buffer)
-	parsedTemplate, err := template.New("test").Funcs(sprig.TxtFuncMap()).Parse(templateText)
+	parsedTemplate, err := newTemplate()
+	if err != nil {
+		return "", err
+	}
+
+	parsedTemplate, err = parsedTemplate.Parse(templateText)
 	if err != nil {
 		return "", err
 	}
@@ -4,6 +4,12 @@
 package slack
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/devatherock/simple-slack/test/helper"
@@ -12,36 +18,167 @@ import (
 
 func TestValidateError(test *testing.T) {
 	cases := []struct {
-		request SlackRequest
+		request  SlackRequest
+		expected string
 	}{
 		{
 			SlackRequest{
 				Text: "hello",
 			},
+			"Required parameters not specified",
 		},
 		{
 			SlackRequest{
 				Webhook: "https://secreturl",
 			},
+			"Required parameters not specified",
+		},
+		{
+			SlackRequest{
+				Text:  "hello",
+				Token: "xoxb-secret",
+			},
+			"Channel is required when using a token",
+		},
+		{
+			SlackRequest{
+				Text:      "hello",
+				Webhook:   "https://secreturl",
+				Timestamp: "1503435956.000247",
+			},
+			"Token is required to update a message",
+		},
+		{
+			SlackRequest{
+				Text:            "hello",
+				Channel:         "general",
+				RocketChatToken: "secret-token",
+				RocketChatUrl:   "https://rocketchat",
+			},
+			"Rocket.Chat URL, user ID and channel are required when using a Rocket.Chat token",
 		},
 	}
-	expected := "Required parameters not specified"
 
 	for _, data := range cases {
 		actual := Validate(data.request)
 
-		assert.Equal(test, expected, actual.Error())
+		assert.Equal(test, data.expected, actual.Error())
 	}
 }
 
 func TestValidateSuccess(test *testing.T) {
-	request := SlackRequest{
-		Text:    "hello",
-		Webhook: "https://secreturl",
+	cases := []SlackRequest{
+		{
+			Text:    "hello",
+			Webhook: "https://secreturl",
+		},
+		{
+			Blocks:  []Block{{Type: DividerBlock}},
+			Webhook: "https://secreturl",
+		},
+		{
+			BlocksTemplate: "- type: divider",
+			Webhook:        "https://secreturl",
+		},
+		{
+			Text:    "hello",
+			Token:   "xoxb-secret",
+			Channel: "general",
+		},
+		{
+			Text:             "hello",
+			Channel:          "general",
+			RocketChatUrl:    "https://rocketchat",
+			RocketChatUserId: "user1",
+			RocketChatToken:  "secret-token",
+		},
+	}
+
+	for _, request := range cases {
+		actual := Validate(request)
+
+		assert.Nil(test, actual)
 	}
-	actual := Validate(request)
+}
+
+func TestPostWithToken(test *testing.T) {
+	var capturedRequest []byte
+	var capturedPath string
+	var headers http.Header
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = io.ReadAll(request.Body)
+		capturedPath = request.URL.Path
+		headers = request.Header
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"ok":true,"channel":"C1234","ts":"1503435956.000247"}`)
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "SLACK_API_HOST", testServer.URL)
+
+	actual, err := Post(SlackRequest{
+		Text:    "Build failed!",
+		Color:   "red",
+		Title:   "Build notification",
+		Channel: "general",
+		Token:   "xoxb-secret",
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, SlackResponse{Channel: "C1234", Timestamp: "1503435956.000247"}, actual)
+	assert.Equal(test, "/api/chat.postMessage", capturedPath)
+	assert.Equal(test, "Bearer xoxb-secret", headers.Get("Authorization"))
+	helper.VerifySlackRequest(test, capturedRequest, map[string]string{
+		"text":  "Build failed!",
+		"color": "red",
+		"title": "Build notification",
+	})
+}
+
+func TestPostUpdate(test *testing.T) {
+	var capturedRequest []byte
+	var capturedPath string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = io.ReadAll(request.Body)
+		capturedPath = request.URL.Path
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"ok":true,"channel":"C1234","ts":"1503435956.000247"}`)
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "SLACK_API_HOST", testServer.URL)
+
+	actual, err := Post(SlackRequest{
+		Text:      "Build succeeded!",
+		Channel:   "C1234",
+		Token:     "xoxb-secret",
+		Timestamp: "1503435956.000247",
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, SlackResponse{Channel: "C1234", Timestamp: "1503435956.000247"}, actual)
+	assert.Equal(test, "/api/chat.update", capturedPath)
+
+	jsonRequest := make(map[string]interface{})
+	json.Unmarshal(capturedRequest, &jsonRequest)
+	assert.Equal(test, "1503435956.000247", jsonRequest["ts"])
+	assert.Equal(test, "C1234", jsonRequest["channel"])
+}
+
+func TestPostWithTokenError(test *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"ok":false,"error":"channel_not_found"}`)
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "SLACK_API_HOST", testServer.URL)
+
+	actual, err := Post(SlackRequest{
+		Text:    "Build failed!",
+		Channel: "general",
+		Token:   "xoxb-secret",
+	})
 
-	assert.Nil(test, actual)
+	assert.Equal(test, "Slack API call failed: channel_not_found", err.Error())
+	assert.Equal(test, SlackResponse{}, actual)
 }
 
 func TestBuildPayload(test *testing.T) {
@@ -57,7 +194,7 @@ func TestBuildPayload(test *testing.T) {
 				Channel: "general",
 			},
 			map[string]interface{}{
-				"attachments": [1]map[string]string{
+				"attachments": [1]map[string]interface{}{
 					{
 						"color": "red",
 						"text":  "Build failed!",
@@ -72,7 +209,7 @@ func TestBuildPayload(test *testing.T) {
 				Text: "Build failed!",
 			},
 			map[string]interface{}{
-				"attachments": [1]map[string]string{
+				"attachments": [1]map[string]interface{}{
 					{
 						"color": "#cfd3d7",
 						"text":  "Build failed!",
@@ -80,14 +217,217 @@ func TestBuildPayload(test *testing.T) {
 				},
 			},
 		},
+		{
+			SlackRequest{
+				Text:      "Build failed!",
+				Color:     "red",
+				Title:     "Build notification",
+				TitleLink: "https://someurl",
+				Collapsed: true,
+				ImageUrl:  "https://someurl/image.png",
+				Alias:     "CI",
+				Avatar:    "https://avatar",
+				Emoji:     ":rocket:",
+			},
+			map[string]interface{}{
+				"attachments": [1]map[string]interface{}{
+					{
+						"color":      "red",
+						"text":       "Build failed!",
+						"title":      "Build notification",
+						"title_link": "https://someurl",
+						"collapsed":  true,
+						"image_url":  "https://someurl/image.png",
+					},
+				},
+				"alias":  "CI",
+				"avatar": "https://avatar",
+				"emoji":  ":rocket:",
+			},
+		},
+	}
+
+	for _, data := range cases {
+		actual, err := buildPayload(context.Background(), data.request)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual)
+	}
+}
+
+func TestBuildPayloadWithBlocks(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "CIRCLE_BUILD_URL", "https://someurl")
+	blocks := []Block{
+		{
+			Type: SectionBlock,
+			Text: &TextObject{Type: Markdown, Text: "Build <{{.CircleBuildUrl}}|failed>"},
+			Fields: []TextObject{
+				{Type: Markdown, Text: "*Branch*"},
+			},
+		},
+		{
+			Type: ActionsBlock,
+			Elements: []Element{
+				{Type: ButtonElement, Text: "Open", Url: "{{.CircleBuildUrl}}"},
+			},
+		},
+	}
+	renderedBlocks := []Block{
+		{
+			Type: HeaderBlock,
+			Text: &TextObject{Type: PlainText, Text: "Build notification"},
+		},
+		{
+			Type: SectionBlock,
+			Text: &TextObject{Type: Markdown, Text: "Build <https://someurl|failed>"},
+			Fields: []TextObject{
+				{Type: Markdown, Text: "*Branch*"},
+			},
+		},
+		{
+			Type: ActionsBlock,
+			Elements: []Element{
+				{Type: ButtonElement, Text: "Open", Url: "https://someurl"},
+			},
+		},
+	}
+
+	cases := []struct {
+		request  SlackRequest
+		expected map[string]interface{}
+	}{
+		{
+			SlackRequest{
+				Text:    "Build failed!",
+				Title:   "Build notification",
+				Channel: "general",
+				Blocks:  blocks,
+			},
+			map[string]interface{}{
+				"blocks":  renderedBlocks,
+				"text":    "Build failed!",
+				"channel": "general",
+			},
+		},
+		{
+			SlackRequest{
+				Title:    "Build notification",
+				Color:    "red",
+				Blocks:   blocks,
+				ColorBar: true,
+			},
+			map[string]interface{}{
+				"attachments": [1]map[string]interface{}{
+					{
+						"color":  "red",
+						"blocks": renderedBlocks,
+					},
+				},
+			},
+		},
 	}
 
 	for _, data := range cases {
-		actual, err := buildPayload(data.request)
+		actual, err := buildPayload(context.Background(), data.request)
 
 		assert.Nil(test, err)
 		assert.Equal(test, data.expected, actual)
 	}
+
+	// Input blocks should not be modified by templating
+	assert.Equal(test, "{{.CircleBuildUrl}}", blocks[1].Elements[0].Url)
+}
+
+func TestParseBlocks(test *testing.T) {
+	expected := []Block{
+		{
+			Type: ContextBlock,
+			Elements: []Element{
+				{Type: Markdown, Text: "Triggered by {{.BuildAuthor}}"},
+				{Type: ImageElement, ImageUrl: "https://someurl/avatar.png", AltText: "avatar"},
+			},
+		},
+		{
+			Type: DividerBlock,
+		},
+		{
+			Type: ActionsBlock,
+			Elements: []Element{
+				{Type: ButtonElement, Text: "Open", Url: "https://someurl", Style: "primary"},
+			},
+		},
+	}
+
+	cases := []string{
+		`{
+			"blocks": [
+				{
+					"type": "context",
+					"elements": [
+						{"type": "mrkdwn", "text": "Triggered by {{.BuildAuthor}}"},
+						{"type": "image", "image_url": "https://someurl/avatar.png", "alt_text": "avatar"}
+					]
+				},
+				{"type": "divider"},
+				{
+					"type": "actions",
+					"elements": [
+						{"type": "button", "text": {"type": "plain_text", "text": "Open"}, "url": "https://someurl", "style": "primary"}
+					]
+				}
+			]
+		}`,
+		`
+- type: context
+  elements:
+    - type: mrkdwn
+      text: "Triggered by {{.BuildAuthor}}"
+    - type: image
+      image_url: https://someurl/avatar.png
+      alt_text: avatar
+- type: divider
+- type: actions
+  elements:
+    - type: button
+      text: Open
+      url: https://someurl
+      style: primary
+`,
+	}
+
+	for _, document := range cases {
+		actual, err := parseBlocks(document)
+
+		assert.Nil(test, err)
+		assert.Equal(test, expected, actual)
+	}
+}
+
+func TestMarshalElement(test *testing.T) {
+	cases := []struct {
+		element  Element
+		expected string
+	}{
+		{
+			Element{Type: Markdown, Text: "*hello*"},
+			`{"type":"mrkdwn","text":"*hello*"}`,
+		},
+		{
+			Element{Type: ButtonElement, Text: "Open", Url: "https://someurl"},
+			`{"type":"button","url":"https://someurl","text":{"type":"plain_text","text":"Open"}}`,
+		},
+		{
+			Element{Type: ImageElement, ImageUrl: "https://someurl", AltText: "avatar"},
+			`{"type":"image","image_url":"https://someurl","alt_text":"avatar"}`,
+		},
+	}
+
+	for _, data := range cases {
+		actual, err := json.Marshal(data.element)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, string(actual))
+	}
 }
 
 func TestGetHighlightColorForDrone(test *testing.T) {
@@ -157,7 +497,7 @@ func TestParseTemplate(test *testing.T) {
 	for _, data := range cases {
 		helper.SetEnvironmentVariable(test, "CIRCLE_BUILD_URL", "https://someurl")
 		helper.SetEnvironmentVariable(test, "WEBHOOK", "https://secreturl")
-		actual, err := parseTemplate(data.template)
+		actual, err := parseTemplate(context.Background(), data.template)
 
 		assert.Nil(test, err)
 		assert.Equal(test, data.expected, actual)
@@ -175,13 +515,58 @@ func TestParseSprigTemplate(test *testing.T) {
 	for _, data := range cases {
 		helper.SetEnvironmentVariable(test, "CIRCLE_BUILD_URL", "https://someurl")
 		helper.SetEnvironmentVariable(test, "WEBHOOK", "https://secreturl")
-		actual, err := parseTemplate(data.template)
+		actual, err := parseTemplate(context.Background(), data.template)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual)
+	}
+}
+
+func TestParseTemplateWithCiContext(test *testing.T) {
+	cases := []struct{ template, expected string }{
+		{
+			"{{.Build.Status}} {{.Repo.Name}}#{{.Build.Number}} on {{.Branch}} by {{.Commit.Author}}",
+			"failure octocat/hello-world#42 on main by octocat",
+		},
+		{
+			"{{.DroneCommitAuthor}} {{.Env.DroneCommitAuthor}} {{.Env.DroneBuildLink}}",
+			"octocat octocat https://someurl",
+		},
+		{
+			"{{.Env.SlackWebhook}}",
+			"<no value>",
+		},
+	}
+
+	for _, data := range cases {
+		helper.SetEnvironmentVariable(test, "DRONE", "true")
+		helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "failure")
+		helper.SetEnvironmentVariable(test, "DRONE_BUILD_NUMBER", "42")
+		helper.SetEnvironmentVariable(test, "DRONE_BUILD_LINK", "https://someurl")
+		helper.SetEnvironmentVariable(test, "DRONE_REPO", "octocat/hello-world")
+		helper.SetEnvironmentVariable(test, "DRONE_COMMIT_BRANCH", "main")
+		helper.SetEnvironmentVariable(test, "DRONE_COMMIT_AUTHOR", "octocat")
+		helper.SetEnvironmentVariable(test, "SLACK_WEBHOOK", "https://secreturl")
+		actual, err := parseTemplate(context.Background(), data.template)
 
 		assert.Nil(test, err)
 		assert.Equal(test, data.expected, actual)
 	}
 }
 
+func TestParseTemplateWithCollidingVariables(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_COMMIT_BRANCH", "main")
+	helper.SetEnvironmentVariable(test, "DRONE_TAG", "v1.0.0")
+	helper.SetEnvironmentVariable(test, "BRANCH", "release")
+	helper.SetEnvironmentVariable(test, "ENV", "production")
+
+	actual, err := parseTemplate(context.Background(), "{{.Branch}} {{.Env}} {{.Tag}}")
+
+	assert.Nil(test, err)
+	assert.Equal(test, "release production v1.0.0", actual)
+}
+
 func TestEnvVariableToCamelCase(test *testing.T) {
 	cases := []struct{ inputVariable, expected string }{
 		{"BUILD_STATUS", "BuildStatus"},
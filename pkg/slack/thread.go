@@ -0,0 +1,236 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// Stores the timestamp of the parent message of each thread
+type ThreadStore interface {
+	Get(key string) (string, error)
+	Put(key string, ts string) error
+}
+
+// Limits of the threads kept by NewMemoryThreadStore. Long enough for the
+// slowest pipelines, while keeping a long running server's memory bounded
+const (
+	defaultThreadTtl  time.Duration = 24 * time.Hour
+	defaultMaxThreads int           = 10000
+)
+
+// Serializes the first posts to each thread, so that concurrent messages
+// don't each start a thread of their own
+var threadLocks = newKeyMutex()
+
+// Thread store that keeps the mapping in memory. Threads expire after the
+// TTL, and the oldest threads are evicted beyond the maximum count
+type MemoryThreadStore struct {
+	mutex      sync.RWMutex
+	threads    map[string]memoryThread
+	ttl        time.Duration
+	maxThreads int
+}
+
+type memoryThread struct {
+	ts      string
+	created time.Time
+}
+
+// Thread store that keeps the mapping in a JSON file, so that it survives
+// across separate invocations of the plugin
+type FileThreadStore struct {
+	mutex sync.Mutex
+	path  string
+}
+
+func NewMemoryThreadStore() *MemoryThreadStore {
+	return NewMemoryThreadStoreWithLimits(defaultThreadTtl, defaultMaxThreads)
+}
+
+// Creates a memory thread store that keeps each thread for the TTL and at
+// most the given number of threads
+func NewMemoryThreadStoreWithLimits(ttl time.Duration, maxThreads int) *MemoryThreadStore {
+	return &MemoryThreadStore{
+		threads:    make(map[string]memoryThread),
+		ttl:        ttl,
+		maxThreads: maxThreads,
+	}
+}
+
+func NewFileThreadStore(path string) *FileThreadStore {
+	return &FileThreadStore{path: path}
+}
+
+func (store *MemoryThreadStore) Get(key string) (string, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	thread, ok := store.threads[key]
+	if !ok || store.expired(thread, time.Now()) {
+		return "", nil
+	}
+
+	return thread.ts, nil
+}
+
+func (store *MemoryThreadStore) Put(key string, ts string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	if _, ok := store.threads[key]; !ok && len(store.threads) >= store.maxThreads {
+		store.evict(now)
+	}
+	store.threads[key] = memoryThread{ts: ts, created: now}
+
+	return nil
+}
+
+func (store *MemoryThreadStore) expired(thread memoryThread, now time.Time) bool {
+	return now.Sub(thread.created) >= store.ttl
+}
+
+// Removes the expired threads, or the oldest thread when none has expired
+func (store *MemoryThreadStore) evict(now time.Time) {
+	oldestKey := ""
+	var oldest time.Time
+	for key, thread := range store.threads {
+		if store.expired(thread, now) {
+			delete(store.threads, key)
+		} else if oldestKey == "" || thread.created.Before(oldest) {
+			oldestKey, oldest = key, thread.created
+		}
+	}
+
+	if len(store.threads) >= store.maxThreads {
+		delete(store.threads, oldestKey)
+	}
+}
+
+func (store *FileThreadStore) Get(key string) (string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	threads, err := store.read()
+	return threads[key], err
+}
+
+func (store *FileThreadStore) Put(key string, ts string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	threads, err := store.read()
+	if err != nil {
+		return err
+	}
+	threads[key] = ts
+
+	data, _ := json.Marshal(threads)
+	return os.WriteFile(store.path, data, 0644)
+}
+
+// Reads all the threads from the file. A missing file means no threads
+func (store *FileThreadStore) read() (threads map[string]string, err error) {
+	threads = make(map[string]string)
+
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return threads, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &threads)
+	return
+}
+
+// Posts the message as a reply in the thread identified by the key, using
+// the default client
+func PostInThread(request SlackRequest, threadKey string, store ThreadStore) (SlackResponse, error) {
+	return defaultClient.SendInThread(context.Background(), request, threadKey, store)
+}
+
+// Posts the message as a reply in the thread identified by the key. The
+// first message posted with a key becomes the parent of the thread. The key
+// is processed as a template and is scoped to the channel
+func (client *Client) SendInThread(ctx context.Context, request SlackRequest, threadKey string, store ThreadStore) (response SlackResponse, err error) {
+	if request.Token == "" && request.RocketChatToken == "" {
+		err = errors.New("Token is required to post in a thread")
+		return
+	}
+
+	key, err := parseTemplate(ctx, threadKey)
+	if err != nil {
+		return
+	}
+	key = request.Channel + ":" + key
+
+	request.ThreadTs, err = store.Get(key)
+	if err != nil {
+		return
+	}
+
+	// Without a parent, the message starts the thread. Other messages with
+	// the key wait for it and reply to it instead
+	if request.ThreadTs == "" {
+		unlock := threadLocks.Lock(key)
+		defer unlock()
+
+		request.ThreadTs, err = store.Get(key)
+		if err != nil {
+			return
+		}
+	}
+
+	response, err = client.Send(ctx, request)
+	if err != nil || request.ThreadTs != "" {
+		return
+	}
+
+	err = store.Put(key, response.Timestamp)
+	return
+}
+
+// Mutex per key. Entries are removed once no one holds or waits for them
+type keyMutex struct {
+	mutex sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	users int
+}
+
+func newKeyMutex() *keyMutex {
+	return &keyMutex{locks: make(map[string]*keyLock)}
+}
+
+// Locks the key and returns the function that unlocks it
+func (keyMutex *keyMutex) Lock(key string) func() {
+	keyMutex.mutex.Lock()
+	lock, ok := keyMutex.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		keyMutex.locks[key] = lock
+	}
+	lock.users++
+	keyMutex.mutex.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		keyMutex.mutex.Lock()
+		defer keyMutex.mutex.Unlock()
+		lock.users--
+		if lock.users == 0 {
+			delete(keyMutex.locks, key)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+//go:build test
+// +build test
+
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThreadStores(test *testing.T) {
+	path := filepath.Join(test.TempDir(), "threads.json")
+	stores := []ThreadStore{
+		NewMemoryThreadStore(),
+		NewFileThreadStore(path),
+	}
+
+	for _, store := range stores {
+		ts, err := store.Get("general:123")
+		assert.Nil(test, err)
+		assert.Equal(test, "", ts)
+
+		assert.Nil(test, store.Put("general:123", "1503435956.000247"))
+		assert.Nil(test, store.Put("general:124", "1503435957.000247"))
+
+		ts, err = store.Get("general:123")
+		assert.Nil(test, err)
+		assert.Equal(test, "1503435956.000247", ts)
+	}
+
+	// Verify a new file store reads the saved threads
+	ts, err := NewFileThreadStore(path).Get("general:124")
+	assert.Nil(test, err)
+	assert.Equal(test, "1503435957.000247", ts)
+}
+
+func TestMemoryThreadStoreLimits(test *testing.T) {
+	store := NewMemoryThreadStoreWithLimits(time.Hour, 2)
+	store.Put("general:1", "1503435956.000241")
+	store.Put("general:2", "1503435956.000242")
+	store.Put("general:2", "1503435956.000243")
+	store.Put("general:3", "1503435956.000244")
+
+	ts, _ := store.Get("general:1")
+	assert.Equal(test, "", ts)
+	ts, _ = store.Get("general:2")
+	assert.Equal(test, "1503435956.000243", ts)
+	ts, _ = store.Get("general:3")
+	assert.Equal(test, "1503435956.000244", ts)
+
+	store = NewMemoryThreadStoreWithLimits(50*time.Millisecond, 2)
+	store.Put("general:1", "1503435956.000241")
+	time.Sleep(60 * time.Millisecond)
+
+	ts, _ = store.Get("general:1")
+	assert.Equal(test, "", ts)
+
+	store.Put("general:2", "1503435956.000242")
+	store.Put("general:3", "1503435956.000243")
+	assert.Equal(test, 2, len(store.threads))
+}
+
+func TestPostInThreadConcurrently(test *testing.T) {
+	var mutex sync.Mutex
+	var threadTimestamps []interface{}
+	requestIndex := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		jsonRequest := make(map[string]interface{})
+		json.Unmarshal(body, &jsonRequest)
+
+		// Gives the other messages time to find no parent
+		time.Sleep(20 * time.Millisecond)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		threadTimestamps = append(threadTimestamps, jsonRequest["thread_ts"])
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(writer, `{"ok":true,"channel":"C1234","ts":"1503435956.00024%d"}`, requestIndex)
+		requestIndex++
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "SLACK_API_HOST", testServer.URL)
+
+	store := NewMemoryThreadStore()
+	request := SlackRequest{
+		Text:    "Workflow completed",
+		Channel: "general",
+		Token:   "xoxb-secret",
+	}
+
+	var waitGroup sync.WaitGroup
+	for index := 0; index < 5; index++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			_, err := PostInThread(request, "pipeline-42", store)
+			assert.Nil(test, err)
+		}()
+	}
+	waitGroup.Wait()
+
+	assert.Equal(test, []interface{}{nil, "1503435956.000240", "1503435956.000240", "1503435956.000240", "1503435956.000240"}, threadTimestamps)
+	assert.Equal(test, 0, len(threadLocks.locks))
+}
+
+func TestPostInThread(test *testing.T) {
+	var capturedRequests []map[string]interface{}
+	requestIndex := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		jsonRequest := make(map[string]interface{})
+		json.Unmarshal(body, &jsonRequest)
+		capturedRequests = append(capturedRequests, jsonRequest)
+
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(writer, `{"ok":true,"channel":"C1234","ts":"1503435956.00024%d"}`, requestIndex)
+		requestIndex++
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "SLACK_API_HOST", testServer.URL)
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_NUMBER", "123")
+
+	store := NewMemoryThreadStore()
+	request := SlackRequest{
+		Text:    "Build started",
+		Channel: "general",
+		Token:   "xoxb-secret",
+	}
+
+	// First message becomes the parent
+	response, err := PostInThread(request, "{{.DroneBuildNumber}}", store)
+	assert.Nil(test, err)
+	assert.Equal(test, "1503435956.000240", response.Timestamp)
+
+	// Later messages are replies
+	request.ReplyBroadcast = true
+	response, err = PostInThread(request, "{{.DroneBuildNumber}}", store)
+	assert.Nil(test, err)
+	assert.Equal(test, "1503435956.000241", response.Timestamp)
+
+	assert.Nil(test, capturedRequests[0]["thread_ts"])
+	assert.Nil(test, capturedRequests[0]["reply_broadcast"])
+	assert.Equal(test, "1503435956.000240", capturedRequests[1]["thread_ts"])
+	assert.Equal(test, true, capturedRequests[1]["reply_broadcast"])
+
+	ts, _ := store.Get("general:123")
+	assert.Equal(test, "1503435956.000240", ts)
+}
+
+func TestPostInThreadWithoutToken(test *testing.T) {
+	request := SlackRequest{
+		Text:    "Build started",
+		Webhook: "https://secreturl",
+	}
+
+	_, err := PostInThread(request, "123", NewMemoryThreadStore())
+	assert.Equal(test, "Token is required to post in a thread", err.Error())
+}
@@ -0,0 +1,70 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// Response of a Slack Web API method
+type webApiResponse struct {
+	Ok      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Ts      string `json:"ts,omitempty"`
+}
+
+// Calls a Slack Web API method with the supplied JSON payload
+func (client *Client) callWebApi(ctx context.Context, method string, token string, data []byte) (response SlackResponse, err error) {
+	req, err := client.newRequest(ctx, client.getBaseUrl()+"/api/"+method, data)
+	if err != nil {
+		return
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	res, err := client.httpClient.Do(req)
+	if err != nil {
+		err = newNetworkError(err)
+		return
+	}
+	defer res.Body.Close()
+	client.logger.Info("Called Slack method ", method, " with http status ", res.StatusCode)
+
+	if res.StatusCode > 399 {
+		err = newResponseError(res)
+		return
+	}
+
+	// Slack indicates failures within the body, with a 200 status
+	responseBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return
+	}
+
+	apiResponse := webApiResponse{}
+	err = json.Unmarshal(responseBody, &apiResponse)
+	if err != nil {
+		return
+	}
+
+	if !apiResponse.Ok {
+		err = newApiError(apiResponse.Error)
+		return
+	}
+
+	response.Channel = apiResponse.Channel
+	response.Timestamp = apiResponse.Ts
+	return
+}
+
+// Reads the Slack API URL from SLACK_API_HOST environment variable
+func getSlackApiUrl() (slackApiUrl string) {
+	slackApiUrl = os.Getenv("SLACK_API_HOST")
+
+	if slackApiUrl == "" {
+		slackApiUrl = "https://slack.com"
+	}
+
+	return
+}
@@ -0,0 +1,168 @@
+package teams
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+)
+
+const provider string = "teams"
+
+// Adaptive Card container styles, used to show the status
+const (
+	goodStyle      string = "good"
+	attentionStyle string = "attention"
+	warningStyle   string = "warning"
+	emphasisStyle  string = "emphasis"
+)
+
+func init() {
+	notifier.Register(provider, newTeamsNotifier)
+}
+
+// Posts messages as Adaptive Cards to a Teams or Power Automate workflow
+// webhook
+type teamsNotifier struct {
+	sender  *notifier.HttpSender
+	webhook string
+}
+
+// Message posted to the webhook, holding the card as an attachment
+type Payload struct {
+	Type        string       `json:"type"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+type Attachment struct {
+	ContentType string  `json:"contentType"`
+	ContentUrl  *string `json:"contentUrl"`
+	Content     Card    `json:"content"`
+}
+
+type Card struct {
+	Schema  string            `json:"$schema"`
+	Type    string            `json:"type"`
+	Version string            `json:"version"`
+	Body    []Element         `json:"body"`
+	Actions []Action          `json:"actions,omitempty"`
+	MsTeams map[string]string `json:"msteams,omitempty"`
+}
+
+// A container, text block or fact set within the card body
+type Element struct {
+	Type   string    `json:"type"`
+	Text   string    `json:"text,omitempty"`
+	Weight string    `json:"weight,omitempty"`
+	Size   string    `json:"size,omitempty"`
+	Wrap   bool      `json:"wrap,omitempty"`
+	Style  string    `json:"style,omitempty"`
+	Bleed  bool      `json:"bleed,omitempty"`
+	Items  []Element `json:"items,omitempty"`
+	Facts  []Fact    `json:"facts,omitempty"`
+}
+
+type Fact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type Action struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	Url   string `json:"url"`
+}
+
+func newTeamsNotifier(config notifier.Config) (notifier.Notifier, error) {
+	if config.Webhook == "" {
+		return nil, errors.New("Webhook is required to post to Teams")
+	}
+
+	return &teamsNotifier{
+		sender:  notifier.NewHttpSender("Teams", config),
+		webhook: config.Webhook,
+	}, nil
+}
+
+func (teams *teamsNotifier) Notify(ctx context.Context, message notifier.Message) (result notifier.Result, err error) {
+	payload, err := BuildPayload(ctx, message)
+	if err != nil {
+		return
+	}
+
+	_, err = teams.sender.PostJson(ctx, teams.webhook, payload)
+	return
+}
+
+// Renders the message as an Adaptive Card. The title and text are shown in
+// a container styled as per the status color, followed by the fields as
+// facts and the links as buttons
+func BuildPayload(ctx context.Context, message notifier.Message) (payload Payload, err error) {
+	message, err = message.Render(ctx)
+	if err != nil {
+		return
+	}
+
+	header := Element{
+		Type:  "Container",
+		Style: containerStyle(message.Color),
+		Bleed: true,
+	}
+	if message.Title != "" {
+		header.Items = append(header.Items, Element{
+			Type:   "TextBlock",
+			Text:   message.Title,
+			Weight: "Bolder",
+			Size:   "Medium",
+			Wrap:   true,
+		})
+	}
+	if message.Text != "" {
+		header.Items = append(header.Items, Element{Type: "TextBlock", Text: message.Text, Wrap: true})
+	}
+
+	card := Card{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body:    []Element{header},
+		MsTeams: map[string]string{"width": "Full"},
+	}
+
+	if len(message.Fields) > 0 {
+		factSet := Element{Type: "FactSet"}
+		for _, field := range message.Fields {
+			factSet.Facts = append(factSet.Facts, Fact{Title: field.Title, Value: field.Value})
+		}
+		card.Body = append(card.Body, factSet)
+	}
+
+	for _, link := range message.Links {
+		card.Actions = append(card.Actions, Action{Type: "Action.OpenUrl", Title: link.Text, Url: link.Url})
+	}
+
+	payload = Payload{
+		Type: "message",
+		Attachments: []Attachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	}
+	return
+}
+
+// Adaptive Cards don't support arbitrary colors, so the status colors and
+// the named colors accepted by Slack are mapped to the closest style
+func containerStyle(color string) string {
+	switch strings.ToLower(color) {
+	case notifier.StatusColor(notifier.StatusSuccess), "good", "green":
+		return goodStyle
+	case notifier.StatusColor(notifier.StatusFailure), "danger", "red":
+		return attentionStyle
+	case "warning", "yellow", "orange":
+		return warningStyle
+	default:
+		return emphasisStyle
+	}
+}
@@ -0,0 +1,117 @@
+//go:build test
+// +build test
+
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "failure")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_LINK", "https://someurl")
+
+	// Test HTTP server
+	var capturedRequest []byte
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = io.ReadAll(request.Body)
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+	defer testServer.Close()
+
+	backend, err := notifier.New("teams", notifier.Config{Webhook: testServer.URL})
+	assert.Nil(test, err)
+
+	_, err = backend.Notify(context.Background(), notifier.Message{
+		Title:  "Build notification",
+		Text:   "Build {{.DroneBuildStatus}}",
+		Fields: []notifier.Field{{Title: "Branch", Value: "master"}},
+		Links:  []notifier.Link{{Text: "Open build", Url: "{{.DroneBuildLink}}"}},
+	})
+	assert.Nil(test, err)
+
+	expected := `{
+		"type": "message",
+		"attachments": [{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"contentUrl": null,
+			"content": {
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type": "AdaptiveCard",
+				"version": "1.4",
+				"msteams": {"width": "Full"},
+				"body": [
+					{
+						"type": "Container",
+						"style": "attention",
+						"bleed": true,
+						"items": [
+							{"type": "TextBlock", "text": "Build notification", "weight": "Bolder", "size": "Medium", "wrap": true},
+							{"type": "TextBlock", "text": "Build failure", "wrap": true}
+						]
+					},
+					{"type": "FactSet", "facts": [{"title": "Branch", "value": "master"}]}
+				],
+				"actions": [{"type": "Action.OpenUrl", "title": "Open build", "url": "https://someurl"}]
+			}
+		}]
+	}`
+	assert.JSONEq(test, expected, string(capturedRequest))
+}
+
+func TestNotifyFailed(test *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusBadRequest)
+		io.WriteString(writer, "Invalid card")
+	}))
+	defer testServer.Close()
+
+	backend, _ := notifier.New("teams", notifier.Config{Webhook: testServer.URL})
+	_, err := backend.Notify(context.Background(), notifier.Message{Text: "Build failed!"})
+
+	var deliveryError *notifier.DeliveryError
+	assert.ErrorAs(test, err, &deliveryError)
+	assert.Equal(test, 400, deliveryError.StatusCode)
+	assert.Equal(test, "HTTP request to Teams failed: Invalid card", err.Error())
+}
+
+func TestNewWithoutWebhook(test *testing.T) {
+	_, err := notifier.New("teams", notifier.Config{})
+
+	assert.Equal(test, "Webhook is required to post to Teams", err.Error())
+}
+
+func TestContainerStyle(test *testing.T) {
+	cases := []struct{ color, expected string }{
+		{"#33ad7f", "good"},
+		{"good", "good"},
+		{"#A1040C", "attention"},
+		{"danger", "attention"},
+		{"warning", "warning"},
+		{"#cfd3d7", "emphasis"},
+		{"#123456", "emphasis"},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, containerStyle(data.color))
+	}
+}
+
+func TestBuildPayloadDefaultStatus(test *testing.T) {
+	payload, err := BuildPayload(context.Background(), notifier.Message{Text: "Build passed", Status: "passed"})
+
+	assert.Nil(test, err)
+	data, _ := json.Marshal(payload)
+	assert.Contains(test, string(data), `"style":"good"`)
+	assert.NotContains(test, string(data), "actions")
+}
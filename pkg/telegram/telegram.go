@@ -0,0 +1,208 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+)
+
+const provider string = "telegram"
+
+// Parse modes supported by the Bot API
+const (
+	HtmlMode       string = "HTML"
+	MarkdownV2Mode string = "MarkdownV2"
+)
+
+// Characters that need escaping in MarkdownV2 text
+var markdownV2Replacer = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)",
+	"~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=",
+	"|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+)
+
+func init() {
+	notifier.Register(provider, newTelegramNotifier)
+}
+
+// Sends messages to a Telegram chat through the Bot API
+type telegramNotifier struct {
+	sender          *notifier.HttpSender
+	token           string
+	chatId          string
+	parseMode       string
+	silentOnSuccess bool
+}
+
+// Request of the sendMessage method
+type Request struct {
+	ChatId              string       `json:"chat_id"`
+	Text                string       `json:"text"`
+	ParseMode           string       `json:"parse_mode"`
+	DisableNotification bool         `json:"disable_notification,omitempty"`
+	ReplyMarkup         *ReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+// Buttons shown below the message
+type ReplyMarkup struct {
+	InlineKeyboard [][]Button `json:"inline_keyboard"`
+}
+
+type Button struct {
+	Text string `json:"text"`
+	Url  string `json:"url"`
+}
+
+// Response of a Bot API method
+type response struct {
+	Ok          bool   `json:"ok"`
+	Description string `json:"description,omitempty"`
+	Result      struct {
+		MessageId int `json:"message_id"`
+	} `json:"result"`
+	Parameters struct {
+		RetryAfter int `json:"retry_after"` // In seconds
+	} `json:"parameters"`
+}
+
+func newTelegramNotifier(config notifier.Config) (notifier.Notifier, error) {
+	if config.Token == "" || config.Channel == "" {
+		return nil, errors.New("Bot token and chat ID are required to send to Telegram")
+	}
+
+	parseMode := config.Setting("parse_mode", HtmlMode)
+	if parseMode != HtmlMode && parseMode != MarkdownV2Mode {
+		return nil, errors.New("Unsupported parse mode " + parseMode)
+	}
+
+	sender := notifier.NewHttpSender("Telegram", config)
+	sender.ReadError = readError
+	silentOnSuccess, _ := strconv.ParseBool(config.Setting("silent_on_success", "false"))
+
+	return &telegramNotifier{
+		sender:          sender,
+		token:           config.Token,
+		chatId:          config.Channel,
+		parseMode:       parseMode,
+		silentOnSuccess: silentOnSuccess,
+	}, nil
+}
+
+func (telegram *telegramNotifier) Notify(ctx context.Context, message notifier.Message) (result notifier.Result, err error) {
+	request, err := BuildRequest(ctx, message, telegram.parseMode)
+	if err != nil {
+		return
+	}
+	request.ChatId = telegram.chatId
+	request.DisableNotification = telegram.silentOnSuccess && message.ResolveStatus() == notifier.StatusSuccess
+
+	responseBody, err := telegram.sender.PostJson(ctx, getTelegramApiUrl()+"/bot"+telegram.token+"/sendMessage", request)
+	if err != nil {
+		err = hideToken(err, telegram.token)
+		return
+	}
+
+	telegramResponse := response{}
+	err = json.Unmarshal(responseBody, &telegramResponse)
+	if err != nil {
+		return
+	}
+
+	result.Channel = telegram.chatId
+	result.Id = strconv.Itoa(telegramResponse.Result.MessageId)
+	return
+}
+
+// Renders the message in the parse mode, led by an emoji for its color or
+// status. The templated values are escaped, so that they are shown as is.
+// Links are shown as buttons
+func BuildRequest(ctx context.Context, message notifier.Message, parseMode string) (request Request, err error) {
+	message, err = message.Render(ctx)
+	if err != nil {
+		return
+	}
+
+	escape, bold := html.EscapeString, func(text string) string { return "<b>" + text + "</b>" }
+	if parseMode == MarkdownV2Mode {
+		escape, bold = markdownV2Replacer.Replace, func(text string) string { return "*" + text + "*" }
+	}
+
+	lines := []string{}
+	if message.Title != "" {
+		lines = append(lines, bold(escape(message.Title)))
+	}
+	if message.Text != "" {
+		lines = append(lines, escape(message.Text))
+	}
+
+	if emoji := message.Emoji(); emoji != "" {
+		if len(lines) == 0 {
+			lines = append(lines, emoji)
+		} else {
+			lines[0] = emoji + " " + lines[0]
+		}
+	}
+
+	if len(message.Fields) > 0 {
+		lines = append(lines, "")
+		for _, field := range message.Fields {
+			lines = append(lines, bold(escape(field.Title))+": "+escape(field.Value))
+		}
+	}
+
+	request.Text = strings.Join(lines, "\n")
+	request.ParseMode = parseMode
+
+	if len(message.Links) > 0 {
+		buttons := []Button{}
+		for _, link := range message.Links {
+			buttons = append(buttons, Button{Text: link.Text, Url: link.Url})
+		}
+		request.ReplyMarkup = &ReplyMarkup{InlineKeyboard: [][]Button{buttons}}
+	}
+
+	return
+}
+
+// Hides the bot token, which is a part of the URL, from errors about the
+// URL, like an invalid API host. Network errors only keep the host
+func hideToken(err error, token string) error {
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		urlError.URL = strings.ReplaceAll(urlError.URL, token, "<token>")
+	}
+
+	return err
+}
+
+// Reads the Bot API URL from TELEGRAM_API_HOST environment variable
+func getTelegramApiUrl() (telegramApiUrl string) {
+	telegramApiUrl = os.Getenv("TELEGRAM_API_HOST")
+
+	if telegramApiUrl == "" {
+		telegramApiUrl = "https://api.telegram.org"
+	}
+
+	return
+}
+
+// Reads the reason and the delay requested on rate limits from the JSON
+// error body of the Bot API
+func readError(deliveryError *notifier.DeliveryError, body []byte) {
+	telegramResponse := response{}
+	if json.Unmarshal(body, &telegramResponse) == nil && telegramResponse.Description != "" {
+		deliveryError.Reason = telegramResponse.Description
+
+		if telegramResponse.Parameters.RetryAfter > 0 {
+			deliveryError.RetryAfter = time.Duration(telegramResponse.Parameters.RetryAfter) * time.Second
+		}
+	}
+}
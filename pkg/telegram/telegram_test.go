@@ -0,0 +1,141 @@
+//go:build test
+// +build test
+
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "failure")
+	helper.SetEnvironmentVariable(test, "DRONE_COMMIT_AUTHOR", "octo<cat>")
+
+	// Test HTTP server
+	var capturedRequest []byte
+	var capturedPath string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		capturedRequest, _ = io.ReadAll(request.Body)
+		capturedPath = request.URL.Path
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"ok":true,"result":{"message_id":42,"chat":{"id":-1001234}}}`)
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "TELEGRAM_API_HOST", testServer.URL)
+
+	backend, err := notifier.New("telegram", notifier.Config{Token: "123:secret", Channel: "-1001234"})
+	assert.Nil(test, err)
+
+	result, err := backend.Notify(context.Background(), notifier.Message{
+		Title:  "Build notification",
+		Text:   "Build {{.DroneBuildStatus}} by {{.DroneCommitAuthor}}",
+		Fields: []notifier.Field{{Title: "Branch", Value: "master"}},
+		Links:  []notifier.Link{{Text: "Open build", Url: "https://someurl"}},
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, notifier.Result{Channel: "-1001234", Id: "42"}, result)
+	assert.Equal(test, "/bot123:secret/sendMessage", capturedPath)
+
+	expected := `{
+		"chat_id": "-1001234",
+		"text": "❌ <b>Build notification</b>\nBuild failure by octo&lt;cat&gt;\n\n<b>Branch</b>: master",
+		"parse_mode": "HTML",
+		"reply_markup": {"inline_keyboard": [[{"text": "Open build", "url": "https://someurl"}]]}
+	}`
+	assert.JSONEq(test, expected, string(capturedRequest))
+}
+
+func TestNotifySilentOnSuccess(test *testing.T) {
+	cases := []struct {
+		status   string
+		expected string
+	}{
+		{"success", `{"chat_id":"42","text":"✅ Build passed","parse_mode":"HTML","disable_notification":true}`},
+		{"failure", `{"chat_id":"42","text":"❌ Build passed","parse_mode":"HTML"}`},
+	}
+
+	for _, data := range cases {
+		var capturedRequest []byte
+		testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			capturedRequest, _ = io.ReadAll(request.Body)
+			fmt.Fprintln(writer, `{"ok":true,"result":{"message_id":1}}`)
+		}))
+		helper.SetEnvironmentVariable(test, "TELEGRAM_API_HOST", testServer.URL)
+
+		backend, _ := notifier.New("telegram", notifier.Config{
+			Token:    "123:secret",
+			Channel:  "42",
+			Settings: map[string]string{"silent_on_success": "true"},
+		})
+		_, err := backend.Notify(context.Background(), notifier.Message{Text: "Build passed", Status: data.status})
+		testServer.Close()
+
+		assert.Nil(test, err)
+		assert.JSONEq(test, data.expected, string(capturedRequest))
+	}
+}
+
+func TestNotifyRateLimited(test *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintln(writer, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 5","parameters":{"retry_after":5}}`)
+	}))
+	defer testServer.Close()
+	helper.SetEnvironmentVariable(test, "TELEGRAM_API_HOST", testServer.URL)
+
+	backend, _ := notifier.New("telegram", notifier.Config{Token: "123:secret", Channel: "42"})
+	_, err := backend.Notify(context.Background(), notifier.Message{Text: "Build failed!"})
+
+	var deliveryError *notifier.DeliveryError
+	assert.ErrorAs(test, err, &deliveryError)
+	assert.True(test, deliveryError.Retryable)
+	assert.Equal(test, 5*time.Second, deliveryError.RetryAfter)
+	assert.Equal(test, "HTTP request to Telegram failed: Too Many Requests: retry after 5", err.Error())
+}
+
+func TestNotifyHidesTokenFromNetworkErrors(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "TELEGRAM_API_HOST", "http://localhost:1")
+
+	backend, _ := notifier.New("telegram", notifier.Config{Token: "123:secret", Channel: "42"})
+	_, err := backend.Notify(context.Background(), notifier.Message{Text: "Build failed!"})
+
+	assert.NotNil(test, err)
+	assert.NotContains(test, err.Error(), "secret")
+	assert.Contains(test, err.Error(), `Post "http://localhost:1": `)
+}
+
+func TestBuildRequestMarkdownV2(test *testing.T) {
+	request, err := BuildRequest(context.Background(), notifier.Message{
+		Title:  "Build #42",
+		Text:   "Fixed bug_1 (see [docs]). Done!",
+		Color:  "#123456",
+		Fields: []notifier.Field{{Title: "Version", Value: "1.2.0-rc"}},
+	}, MarkdownV2Mode)
+
+	assert.Nil(test, err)
+	assert.Equal(test, Request{
+		Text:      "*Build \\#42*\nFixed bug\\_1 \\(see \\[docs\\]\\)\\. Done\\!\n\n*Version*: 1\\.2\\.0\\-rc",
+		ParseMode: "MarkdownV2",
+	}, request)
+}
+
+func TestNewInvalidConfig(test *testing.T) {
+	_, err := notifier.New("telegram", notifier.Config{Channel: "42"})
+	assert.Equal(test, "Bot token and chat ID are required to send to Telegram", err.Error())
+
+	_, err = notifier.New("telegram", notifier.Config{Token: "123:secret", Channel: "42", Settings: map[string]string{"parse_mode": "Markdown"}})
+	assert.Equal(test, "Unsupported parse mode Markdown", err.Error())
+}
@@ -0,0 +1,152 @@
+package zulip
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+)
+
+const provider string = "zulip"
+
+// Topic used when none is specified, as stream messages need one
+const defaultTopic string = "builds"
+
+func init() {
+	notifier.Register(provider, newZulipNotifier)
+}
+
+// Posts messages to a Zulip stream through the messages API
+type zulipNotifier struct {
+	sender *notifier.HttpSender
+	site   string
+	stream string
+	topic  string
+}
+
+// Response of the messages API
+type response struct {
+	Result  string `json:"result"`
+	Message string `json:"msg"`
+	Id      int    `json:"id"`
+}
+
+func newZulipNotifier(config notifier.Config) (notifier.Notifier, error) {
+	site := config.Setting("zulip_site", "")
+	email := config.Setting("zulip_email", "")
+	stream := config.Setting("stream", config.Channel)
+
+	if site == "" || email == "" || config.Token == "" {
+		return nil, errors.New("Site, bot email and API key are required to post to Zulip")
+	}
+
+	if stream == "" {
+		return nil, errors.New("Stream is required to post to Zulip")
+	}
+
+	sender := notifier.NewHttpSender("Zulip", config)
+	sender.ReadError = readError
+	sender.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(email+":"+config.Token))
+
+	return &zulipNotifier{
+		sender: sender,
+		site:   strings.TrimSuffix(site, "/"),
+		stream: stream,
+		topic:  config.Setting("topic", defaultTopic),
+	}, nil
+}
+
+func (zulip *zulipNotifier) Notify(ctx context.Context, message notifier.Message) (result notifier.Result, err error) {
+	content, err := BuildContent(ctx, message)
+	if err != nil {
+		return
+	}
+
+	topic, err := notifier.ParseTemplate(ctx, zulip.topic)
+	if err != nil {
+		return
+	}
+
+	form := url.Values{}
+	form.Set("type", "stream")
+	form.Set("to", zulip.stream)
+	form.Set("topic", topic)
+	form.Set("content", content)
+
+	responseBody, err := zulip.sender.Send(
+		ctx,
+		"POST",
+		zulip.site+"/api/v1/messages",
+		"application/x-www-form-urlencoded",
+		[]byte(form.Encode()),
+	)
+	if err != nil {
+		return
+	}
+
+	zulipResponse := response{}
+	err = json.Unmarshal(responseBody, &zulipResponse)
+	if err != nil {
+		return
+	}
+
+	result.Channel = zulip.stream
+	result.Id = strconv.Itoa(zulipResponse.Id)
+	return
+}
+
+// Renders the message as Zulip markdown, led by an emoji for its color or
+// status. Fields are listed below the text, followed by the links
+func BuildContent(ctx context.Context, message notifier.Message) (content string, err error) {
+	message, err = message.Render(ctx)
+	if err != nil {
+		return
+	}
+
+	lines := []string{}
+	if message.Title != "" {
+		lines = append(lines, "**"+message.Title+"**")
+	}
+	if message.Text != "" {
+		lines = append(lines, message.Text)
+	}
+
+	if emoji := message.Emoji(); emoji != "" {
+		if len(lines) == 0 {
+			lines = append(lines, emoji)
+		} else {
+			lines[0] = emoji + " " + lines[0]
+		}
+	}
+
+	if len(message.Fields) > 0 {
+		lines = append(lines, "")
+		for _, field := range message.Fields {
+			lines = append(lines, "**"+field.Title+"**: "+field.Value)
+		}
+	}
+
+	if len(message.Links) > 0 {
+		links := []string{}
+		for _, link := range message.Links {
+			links = append(links, "["+link.Text+"]("+link.Url+")")
+		}
+		lines = append(lines, "", strings.Join(links, " | "))
+	}
+
+	content = strings.Join(lines, "\n")
+	return
+}
+
+// Reads the reason from Zulip's JSON error body
+func readError(deliveryError *notifier.DeliveryError, body []byte) {
+	zulipResponse := response{}
+	if json.Unmarshal(body, &zulipResponse) == nil && zulipResponse.Message != "" {
+		deliveryError.Reason = zulipResponse.Message
+	}
+}
@@ -0,0 +1,134 @@
+//go:build test
+// +build test
+
+package zulip
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/devatherock/simple-slack/pkg/notifier"
+	"github.com/devatherock/simple-slack/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "DRONE", "true")
+	helper.SetEnvironmentVariable(test, "DRONE_BUILD_STATUS", "failure")
+	helper.SetEnvironmentVariable(test, "DRONE_REPO_NAME", "hello-world")
+
+	// Test HTTP server
+	var capturedForm url.Values
+	var capturedPath, capturedEmail, capturedKey string
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		request.ParseForm()
+		capturedForm = request.PostForm
+		capturedPath = request.URL.Path
+		capturedEmail, capturedKey, _ = request.BasicAuth()
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(writer, `{"result":"success","msg":"","id":42}`)
+	}))
+	defer testServer.Close()
+
+	backend, err := notifier.New("zulip", notifier.Config{
+		Token: "secret-key",
+		Settings: map[string]string{
+			"zulip_site":  testServer.URL + "/",
+			"zulip_email": "ci-bot@example.zulipchat.com",
+			"stream":      "builds",
+			"topic":       "{{.DroneRepoName}}",
+		},
+	})
+	assert.Nil(test, err)
+
+	result, err := backend.Notify(context.Background(), notifier.Message{
+		Title:  "Build notification",
+		Text:   "Build {{.DroneBuildStatus}}",
+		Fields: []notifier.Field{{Title: "Branch", Value: "master"}},
+		Links:  []notifier.Link{{Text: "Open build", Url: "https://someurl"}},
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, notifier.Result{Channel: "builds", Id: "42"}, result)
+	assert.Equal(test, "/api/v1/messages", capturedPath)
+	assert.Equal(test, "ci-bot@example.zulipchat.com", capturedEmail)
+	assert.Equal(test, "secret-key", capturedKey)
+	assert.Equal(test, url.Values{
+		"type":    {"stream"},
+		"to":      {"builds"},
+		"topic":   {"hello-world"},
+		"content": {"❌ **Build notification**\nBuild failure\n\n**Branch**: master\n\n[Open build](https://someurl)"},
+	}, capturedForm)
+}
+
+func TestNotifyFailed(test *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(writer, `{"result":"error","msg":"Stream 'builds' does not exist","code":"STREAM_DOES_NOT_EXIST"}`)
+	}))
+	defer testServer.Close()
+
+	backend, _ := notifier.New("zulip", notifier.Config{
+		Token:    "secret-key",
+		Channel:  "builds",
+		Settings: map[string]string{"zulip_site": testServer.URL, "zulip_email": "ci-bot@example.zulipchat.com"},
+	})
+	_, err := backend.Notify(context.Background(), notifier.Message{Text: "Build failed!"})
+
+	var deliveryError *notifier.DeliveryError
+	assert.ErrorAs(test, err, &deliveryError)
+	assert.Equal(test, "Stream 'builds' does not exist", deliveryError.Reason)
+	assert.Equal(test, "HTTP request to Zulip failed: Stream 'builds' does not exist", err.Error())
+}
+
+func TestNewInvalidConfig(test *testing.T) {
+	cases := []struct {
+		config   notifier.Config
+		expected string
+	}{
+		{
+			notifier.Config{Channel: "builds", Settings: map[string]string{"zulip_site": "https://zulip", "zulip_email": "bot@zulip"}},
+			"Site, bot email and API key are required to post to Zulip",
+		},
+		{
+			notifier.Config{Token: "secret-key", Channel: "builds", Settings: map[string]string{"zulip_email": "bot@zulip"}},
+			"Site, bot email and API key are required to post to Zulip",
+		},
+		{
+			notifier.Config{Token: "secret-key", Settings: map[string]string{"zulip_site": "https://zulip", "zulip_email": "bot@zulip"}},
+			"Stream is required to post to Zulip",
+		},
+	}
+
+	for _, data := range cases {
+		_, err := notifier.New("zulip", data.config)
+		assert.Equal(test, data.expected, err.Error())
+	}
+}
+
+func TestBuildContent(test *testing.T) {
+	cases := []struct {
+		message  notifier.Message
+		expected string
+	}{
+		{notifier.Message{Text: "Build passed", Color: "good"}, "✅ Build passed"},
+		{notifier.Message{Text: "Build passed", Status: "success"}, "✅ Build passed"},
+		{notifier.Message{Text: "Build unstable", Color: "warning"}, "⚠️ Build unstable"},
+		{notifier.Message{Text: "Build running", Status: "running"}, "⏳ Build running"},
+		{notifier.Message{Text: "Build canceled", Status: "canceled"}, "Build canceled"},
+		{notifier.Message{Text: "Build canceled", Color: "#123456", Status: "failure"}, "Build canceled"},
+		{notifier.Message{Title: "Build", Color: "red"}, "❌ **Build**"},
+	}
+
+	for _, data := range cases {
+		actual, err := BuildContent(context.Background(), data.message)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual)
+	}
+}